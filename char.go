@@ -0,0 +1,142 @@
+package goscheme
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Char represents the character in scheme.
+type Char rune
+
+// charNames maps the names of character literals to the characters, e.g. #\space.
+var charNames = map[string]Char{
+	"space":     ' ',
+	"newline":   '\n',
+	"tab":       '\t',
+	"return":    '\r',
+	"nul":       0,
+	"null":      0,
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+}
+
+// String returns the external representation of the character, e.g. #\a or #\space.
+func (c Char) String() string {
+	for name, ch := range charNames {
+		if ch == c && name != "null" {
+			return `#\` + name
+		}
+	}
+	return `#\` + string(rune(c))
+}
+
+// IsChar checks whether the expression represents a character.
+func IsChar(exp Expression) bool {
+	switch v := exp.(type) {
+	case Char:
+		return true
+	case string:
+		_, ok := parseChar(v)
+		return ok
+	default:
+		return false
+	}
+}
+
+// parseChar parses the character literal token.
+func parseChar(token string) (Char, bool) {
+	if !strings.HasPrefix(token, `#\`) || len(token) < 3 {
+		return 0, false
+	}
+	name := token[2:]
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) {
+		return Char(r), true
+	}
+	c, ok := charNames[strings.ToLower(name)]
+	return c, ok
+}
+
+func expressionToChar(exp Expression) (Char, error) {
+	switch v := exp.(type) {
+	case Char:
+		return v, nil
+	case string:
+		if c, ok := parseChar(v); ok {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a character", valueToString(exp))
+}
+
+func charToIntegerFunc(args ...Expression) (Expression, error) {
+	c, err := expressionToChar(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Integer(c), nil
+}
+
+func integerToCharFunc(args ...Expression) (Expression, error) {
+	i, ok := args[0].(Integer)
+	if !ok || i < 0 || i > unicode.MaxRune {
+		return UndefObj, fmt.Errorf("integer->char: %v is not a valid code point", valueToString(args[0]))
+	}
+	return Char(i), nil
+}
+
+// compareChars checks every adjacent pair of characters with predicate.
+func compareChars(args []Expression, predicate func(a, b Char) bool) (Expression, error) {
+	for i := 0; i < len(args)-1; i++ {
+		a, err := expressionToChar(args[i])
+		if err != nil {
+			return UndefObj, err
+		}
+		b, err := expressionToChar(args[i+1])
+		if err != nil {
+			return UndefObj, err
+		}
+		if !predicate(a, b) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func charEqualFunc(args ...Expression) (Expression, error) {
+	return compareChars(args, func(a, b Char) bool { return a == b })
+}
+
+func charLessFunc(args ...Expression) (Expression, error) {
+	return compareChars(args, func(a, b Char) bool { return a < b })
+}
+
+func charUpcaseFunc(args ...Expression) (Expression, error) {
+	c, err := expressionToChar(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Char(unicode.ToUpper(rune(c))), nil
+}
+
+func charDowncaseFunc(args ...Expression) (Expression, error) {
+	c, err := expressionToChar(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Char(unicode.ToLower(rune(c))), nil
+}
+
+// charClassifier returns the builtin checking whether the character is in the class reported by fn.
+func charClassifier(fn func(rune) bool) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		c, err := expressionToChar(args[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		return fn(rune(c)), nil
+	}
+}
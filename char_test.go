@@ -0,0 +1,49 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChar(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`#\a`, Char('a')},
+		{`#\space`, Char(' ')},
+		{`#\newline`, Char('\n')},
+		{`#\tab`, Char('\t')},
+		{`#\(`, Char('(')},
+		{`'(#\a #\))`, &Pair{Char('a'), &Pair{Char(')'), NilObj}}},
+		{`(char->integer #\A)`, Integer(65)},
+		{`(integer->char 97)`, Char('a')},
+		{`(char=? #\a #\a)`, true},
+		{`(char<? #\a #\b #\c)`, true},
+		{`(char<? #\b #\a)`, false},
+		{`(char-upcase #\a)`, Char('A')},
+		{`(char-downcase #\A)`, Char('a')},
+		{`(char-alphabetic? #\a)`, true},
+		{`(char-alphabetic? #\1)`, false},
+		{`(char-alphabetic? #\λ)`, true},
+		{`(char-numeric? #\7)`, true},
+		{`(char-numeric? #\٣)`, true},
+		{`(char-numeric? #\a)`, false},
+		{`(char-whitespace? #\space)`, true},
+		{`(char-whitespace? #\tab)`, true},
+		{`(char-whitespace? #\a)`, false},
+		{`(char-upper-case? #\A)`, true},
+		{`(char-upper-case? #\Ä)`, true},
+		{`(char-upper-case? #\a)`, false},
+		{`(char-lower-case? #\ß)`, true},
+		{`(char-lower-case? #\1)`, false},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+	assert.Equal(t, `#\a`, valueToString(Char('a')))
+	assert.Equal(t, `#\space`, valueToString(Char(' ')))
+}
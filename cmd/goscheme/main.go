@@ -22,5 +22,7 @@ func main() {
 		}
 		interpreter = goscheme.NewFileInterpreter(file)
 	}
-	interpreter.Run()
+	if err := interpreter.Run(); err != nil {
+		fmt.Println(err)
+	}
 }
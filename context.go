@@ -0,0 +1,114 @@
+package goscheme
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// contextCheckInterval is the number of evaluation steps between the checks of the context.
+const contextCheckInterval = 1024
+
+// evalState is the state of the evaluation in a global environment, it is shared by the environments extended
+// from the global one and safe for concurrent use.
+type evalState struct {
+	// mu guards output and input
+	mu sync.Mutex
+	// sandbox disables the access to the files and the process
+	sandbox bool
+	// output is the default port of the output procedures, os.Stdout if nil
+	output *Port
+	// input is the default port of the input procedures, os.Stdin if nil
+	input *Port
+}
+
+// dynamicState is the state of the dynamic extent of an evaluation. It is passed from the caller to the procedure
+// called instead of following the lexical environments, so the evaluations sharing a global environment do not
+// affect each other.
+type dynamicState struct {
+	// eval is the evaluation started by EvalContext or EvalWithLimit, nil if neither applies
+	eval *evaluation
+	// parameters are the values bound by parameterize
+	parameters *parameterBinding
+	// handlers are the exception handlers installed by with-exception-handler
+	handlers *handlerBinding
+	// output is the output port bound by with-output-to-string, nil if not bound
+	output *Port
+}
+
+// step is called on every iteration of the evaluation loop, it returns the error to abort the evaluation.
+func (d *dynamicState) step() error {
+	if d == nil || d.eval == nil {
+		return nil
+	}
+	return d.eval.step()
+}
+
+// evaluation is the state of a call to EvalContext or EvalWithLimit, it is only used by the goroutine running the
+// evaluation.
+type evaluation struct {
+	// outer is the enclosing evaluation, its context and limit still apply
+	outer *evaluation
+	// ctx aborts the evaluation when done, nil if not cancellable
+	ctx context.Context
+	// steps counts the iterations of the evaluation loop
+	steps uint64
+	// stepLimit aborts the evaluation once steps exceeds it, zero means unlimited
+	stepLimit uint64
+}
+
+// errStepLimitExceeded is returned when the evaluation runs out of the steps given by EvalWithLimit.
+var errStepLimitExceeded = errors.New("step limit exceeded")
+
+// isAbort checks whether the error aborts the evaluation by EvalContext or EvalWithLimit, which can not be caught by
+// guard and the exception handlers.
+func isAbort(err error) bool {
+	return errors.Is(err, errStepLimitExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// step counts the step in e and the enclosing evaluations.
+func (e *evaluation) step() error {
+	for ; e != nil; e = e.outer {
+		e.steps++
+		if e.stepLimit != 0 && e.steps > e.stepLimit {
+			return errStepLimitExceeded
+		}
+		if e.ctx != nil && e.steps%contextCheckInterval == 0 {
+			if err := e.ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withEvaluation returns the environment sharing the bindings of env, in which the expressions are evaluated as
+// part of ev. The evaluation in progress in env encloses ev.
+func (e *Env) withEvaluation(ev *evaluation) *Env {
+	d := e.copyDynamic()
+	ev.outer = d.eval
+	d.eval = ev
+	return e.withDynamic(&d)
+}
+
+// EvalContext evaluates the expression like Eval, the evaluation is aborted with the error of ctx once ctx is
+// done. The context is checked periodically, so a long-running Go function called by the expression is not
+// interrupted until it returns. The context only applies to this evaluation, including the procedures it calls,
+// so the other evaluations in the same global environment are not affected.
+func EvalContext(ctx context.Context, exp Expression, env *Env) (Expression, error) {
+	if err := ctx.Err(); err != nil {
+		return UndefObj, newSchemeError(err)
+	}
+	return Eval(exp, env.withEvaluation(&evaluation{ctx: ctx}))
+}
+
+// EvalWithLimit evaluates the expression like Eval, the evaluation is aborted with the "step limit exceeded" error
+// once it takes more than maxSteps iterations of the evaluation loop. Zero maxSteps means unlimited. The limit of
+// the enclosing EvalWithLimit still applies to the nested one. Only the steps of this evaluation are counted.
+func EvalWithLimit(exp Expression, env *Env, maxSteps uint64) (Expression, error) {
+	if maxSteps == 0 {
+		return Eval(exp, env)
+	}
+	return Eval(exp, env.withEvaluation(&evaluation{stepLimit: maxSteps}))
+}
@@ -0,0 +1,113 @@
+package goscheme
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEvalContext(t *testing.T) {
+	env := NewBuiltinEnv()
+	EvalAll(strToToken(`(define (loop) (loop)) (define (count n) (if (= n 0) 'done (count (- n 1))))`), env)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := EvalContext(ctx, strToToken(`(loop)`)[0], env)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the nested evaluation is aborted too
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = EvalContext(ctx, strToToken(`(map (lambda (x) (loop)) '(1 2))`)[0], env)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, err = EvalContext(ctx, strToToken(`1`)[0], env)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// the context is only effective during EvalContext
+	ret, err := EvalContext(context.Background(), strToToken(`(count 10000)`)[0], env)
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+	ret, err = Eval(strToToken(`(count 10000)`)[0], env)
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+}
+
+func TestEvalWithLimit(t *testing.T) {
+	env := NewBuiltinEnv()
+	EvalAll(strToToken(`(define (loop) (loop)) (define (count n) (if (= n 0) 'done (count (- n 1))))`), env)
+
+	_, err := EvalWithLimit(strToToken(`(loop)`)[0], env, 10000)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.EqualError(t, err, "step limit exceeded")
+	assert.True(t, errors.Is(err, errStepLimitExceeded))
+
+	ret, err := EvalWithLimit(strToToken(`(count 100)`)[0], env, 10000)
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+
+	// zero means unlimited
+	ret, err = EvalWithLimit(strToToken(`(count 100000)`)[0], env, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+
+	// the limit is only effective during EvalWithLimit
+	_, err = EvalWithLimit(strToToken(`(count 100000)`)[0], env, 1000)
+	assert.NotNil(t, err)
+	ret, err = Eval(strToToken(`(count 100000)`)[0], env)
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+}
+
+func TestEvalLimitPerCall(t *testing.T) {
+	env := NewBuiltinEnv()
+	EvalAll(strToToken(`(define (loop) (loop)) (define (count n) (if (= n 0) 'done (count (- n 1))))`), env)
+
+	// the limit of one evaluation does not apply to the others running in the same global environment
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := EvalContext(ctx, strToToken(`(loop)`)[0], NewChildEnv(env))
+		done <- err
+	}()
+	_, err := EvalWithLimit(strToToken(`(loop)`)[0], NewChildEnv(env), 1000)
+	assert.True(t, errors.Is(err, errStepLimitExceeded))
+	ret, err := Eval(strToToken(`(count 10000)`)[0], NewChildEnv(env))
+	assert.Nil(t, err)
+	assert.Equal(t, Quote("done"), ret)
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	// the procedures and promises created by a limited evaluation are not limited when used later
+	_, err = EvalWithLimit(strToToken(`(begin (define (f n) (count n)) (define ones (cons-stream 1 ones)))`)[0], env, 100)
+	assert.Nil(t, err)
+	ret, err = Eval(strToToken(`(list (f 10000) (stream-ref ones 1000))`)[0], env)
+	assert.Nil(t, err)
+	assert.Equal(t, "(done 1)", valueToString(ret))
+}
+
+func TestEvalAbortNotCaught(t *testing.T) {
+	env := NewBuiltinEnv()
+	EvalAll(strToToken(`(define (loop) (loop))`), env)
+
+	// guard and the exception handlers can not swallow the abort of the host
+	inputs := []string{
+		`(let outer ((n 0)) (guard (e (#t (outer (+ n 1)))) (loop)))`,
+		`(let outer ((n 0)) (with-exception-handler (lambda (e) (outer (+ n 1))) (lambda () (loop))))`,
+	}
+	for _, input := range inputs {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := EvalContext(ctx, strToToken(input)[0], env)
+		cancel()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), input)
+
+		_, err = EvalWithLimit(strToToken(input)[0], env, 10000)
+		assert.True(t, errors.Is(err, errStepLimitExceeded), input)
+	}
+}
@@ -0,0 +1,182 @@
+package goscheme
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// NewBuiltinEnv returns a new global environment with the builtin syntax, functions and procedures, the host
+// program embedding the interpreter evaluates the expressions in it.
+func NewBuiltinEnv() *Env {
+	return setupBuiltinEnv()
+}
+
+// unsafeFunctions are the builtin functions accessing the files or the process, they raise error in the sandbox.
+var unsafeFunctions = []Symbol{"exit", "open-input-file"}
+
+// NewSandboxEnv returns a new global environment like NewBuiltinEnv without the access to the files and the
+// process, load and the functions accessing them raise error instead. It is used to evaluate the untrusted code.
+func NewSandboxEnv() *Env {
+	env := setupBuiltinEnv()
+	env.state.sandbox = true
+	for _, name := range unsafeFunctions {
+		name := name
+		env.Set(name, NewFunction(string(name), func(_ ...Expression) (Expression, error) {
+			return UndefObj, fmt.Errorf("%s: not allowed in sandbox", name)
+		}, -1, -1))
+	}
+	return env
+}
+
+// RegisterBuiltin binds the Go function to name in env so the scheme code can call it with any number of
+// arguments. The error returned by fn is raised as the error of the call.
+func RegisterBuiltin(env *Env, name string, fn func(args ...Expression) (Expression, error)) {
+	env.Set(Symbol(name), NewFunction(name, fn, -1, -1))
+}
+
+// ToGo converts the scheme value into the native Go value recursively:
+// exact integers become int64 or *big.Int if too large, other numbers become float64,
+// strings and symbols become string, characters become rune, booleans become bool,
+// lists and vectors become []interface{}, the pair not forming a proper list becomes [2]interface{},
+// hash tables become map[interface{}]interface{} and the unspecified value becomes nil.
+// It is an error to convert the other values such as procedures, the value should not be circular.
+func ToGo(exp Expression) (interface{}, error) {
+	switch v := exp.(type) {
+	case Integer:
+		return int64(v), nil
+	case *big.Int:
+		return new(big.Int).Set(v), nil
+	case *big.Rat, Number:
+		return toFloat(v), nil
+	case String:
+		return string(v), nil
+	case Quote:
+		return string(v), nil
+	case Char:
+		return rune(v), nil
+	case bool:
+		return v, nil
+	case Undef:
+		return nil, nil
+	case NilType:
+		return []interface{}{}, nil
+	case *Pair:
+		if !v.IsList() {
+			return pairToGo(v)
+		}
+		return slicesToGo(extractList(v))
+	case *Vector:
+		return slicesToGo(v.Elements)
+	case *HashTable:
+		m := make(map[interface{}]interface{}, len(v.entries))
+		for _, entry := range v.entries {
+			key, err := ToGo(entry.key)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("ToGo: hash table key %v cannot be a map key", valueToString(entry.key))
+			}
+			value, err := ToGo(entry.value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("ToGo: cannot convert %v", valueToString(exp))
+	}
+}
+
+func pairToGo(p *Pair) (interface{}, error) {
+	car, err := ToGo(p.Car)
+	if err != nil {
+		return nil, err
+	}
+	cdr, err := ToGo(p.Cdr)
+	if err != nil {
+		return nil, err
+	}
+	return [2]interface{}{car, cdr}, nil
+}
+
+func slicesToGo(exps []Expression) (interface{}, error) {
+	ret := make([]interface{}, len(exps))
+	for i, e := range exps {
+		v, err := ToGo(e)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// FromGo converts the native Go value into the scheme value recursively:
+// integers become exact integers, floats become inexact numbers, strings become strings, booleans become booleans,
+// slices and arrays become lists, maps become association lists sorted by keys and nil becomes the empty list.
+// The scheme values are returned as is. It is an error to convert the other values such as structs.
+func FromGo(v interface{}) (Expression, error) {
+	switch x := v.(type) {
+	case nil:
+		return NilObj, nil
+	case Integer, Number, String, Quote, Char, bool, NilType, Undef, *Pair, *Vector, *HashTable:
+		return x, nil
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Set(x)), nil
+	case *big.Rat:
+		return normalizeRat(new(big.Rat).Set(x)), nil
+	case string:
+		return String(x), nil
+	case Expression:
+		rv := reflect.ValueOf(x)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return Integer(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return normalizeBigInt(new(big.Int).SetUint64(rv.Uint())), nil
+		case reflect.Float32, reflect.Float64:
+			return Number(rv.Float()), nil
+		case reflect.Slice, reflect.Array:
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				return NilObj, nil
+			}
+			elements := make([]Expression, rv.Len())
+			for i := range elements {
+				e, err := FromGo(rv.Index(i).Interface())
+				if err != nil {
+					return UndefObj, err
+				}
+				elements[i] = e
+			}
+			return listImpl(elements...)
+		case reflect.Map:
+			return mapFromGo(rv)
+		}
+	}
+	return UndefObj, fmt.Errorf("FromGo: cannot convert %v of type %T", v, v)
+}
+
+// mapFromGo converts the map into the association list, the entries are sorted by keys to be deterministic.
+func mapFromGo(m reflect.Value) (Expression, error) {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	entries := make([]Expression, len(keys))
+	for i, k := range keys {
+		key, err := FromGo(k.Interface())
+		if err != nil {
+			return UndefObj, err
+		}
+		value, err := FromGo(m.MapIndex(k).Interface())
+		if err != nil {
+			return UndefObj, err
+		}
+		entries[i] = &Pair{key, value}
+	}
+	return listImpl(entries...)
+}
@@ -0,0 +1,168 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterBuiltin(t *testing.T) {
+	env := NewBuiltinEnv()
+	RegisterBuiltin(env, "double", func(args ...Expression) (Expression, error) {
+		n, ok := args[0].(Integer)
+		if !ok {
+			return UndefObj, errors.New("double: not an integer")
+		}
+		return n * 2, nil
+	})
+
+	ret, err := EvalAll(strToToken(`(map double '(1 2 3))`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "(2 4 6)", valueToString(ret))
+
+	_, err = EvalAll(strToToken(`(double "a")`), env)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.EqualError(t, err, "double: not an integer")
+
+	// the other environments are not affected
+	_, err = EvalAll(strToToken(`(double 1)`), NewBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func ExampleRegisterBuiltin() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello from "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	env := NewBuiltinEnv()
+	RegisterBuiltin(env, "http-get", func(args ...Expression) (Expression, error) {
+		url, ok := args[0].(String)
+		if !ok || len(args) != 1 {
+			return UndefObj, errors.New("http-get: requires an url string")
+		}
+		resp, err := http.Get(string(url))
+		if err != nil {
+			return UndefObj, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return UndefObj, err
+		}
+		return String(body), nil
+	})
+	env.Set("server", String(server.URL))
+
+	tokens := NewTokenizerFromString(`(http-get (string-append server "/scheme"))`).Tokens()
+	exps, _ := Parse(&tokens)
+	ret, err := EvalAll(exps, env)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(ret.(String)))
+	// Output: hello from /scheme
+}
+
+func TestToGo(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`1`, int64(1)},
+		{`1.5`, 1.5},
+		{`(/ 1 2)`, 0.5},
+		{`"str"`, "str"},
+		{`'sym`, "sym"},
+		{`#\a`, 'a'},
+		{`#t`, true},
+		{`'()`, []interface{}{}},
+		{`(list 1 "a" (list #f))`, []interface{}{int64(1), "a", []interface{}{false}}},
+		{`(cons 1 2)`, [2]interface{}{int64(1), int64(2)}},
+		{`(vector 1 (vector 2))`, []interface{}{int64(1), []interface{}{int64(2)}}},
+		{`(define h (make-hash-table)) (hash-table-set! h "k" '(1)) h`,
+			map[interface{}]interface{}{"k": []interface{}{int64(1)}}},
+		{`(if #f #f)`, nil},
+	}
+	for _, c := range testCases {
+		exp, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		ret, err := ToGo(exp)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	big, _ := EvalAll(strToToken(`(* 99999999999 99999999999)`), NewBuiltinEnv())
+	ret, err := ToGo(big)
+	assert.Nil(t, err)
+	assert.Equal(t, "9999999999800000000001", fmt.Sprint(ret))
+
+	errorCases := []string{`car`, `(lambda (x) x)`, `(define h (make-hash-table)) (hash-table-set! h '(1) 1) h`}
+	for _, c := range errorCases {
+		exp, _ := EvalAll(strToToken(c), NewBuiltinEnv())
+		_, err := ToGo(exp)
+		assert.NotNil(t, err, c)
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	testCases := []struct {
+		input    interface{}
+		expected string
+	}{
+		{1, "1"},
+		{uint8(2), "2"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{1.5, "1.5"},
+		{"str", `"str"`},
+		{true, "#t"},
+		{nil, "()"},
+		{[]int{1, 2}, "(1 2)"},
+		{[2]string{"a", "b"}, `("a" "b")`},
+		{[]interface{}{1, []interface{}{"a", 2.5}}, `(1 ("a" 2.5))`},
+		{map[string]int{"b": 2, "a": 1}, `(("a" . 1) ("b" . 2))`},
+		{map[string][]int{"a": {1}}, `(("a" 1))`},
+		{Quote("sym"), "sym"},
+	}
+	for _, c := range testCases {
+		ret, err := FromGo(c.input)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, valueToString(ret))
+	}
+
+	_, err := FromGo(struct{}{})
+	assert.NotNil(t, err)
+	_, err = FromGo([]interface{}{func() {}})
+	assert.NotNil(t, err)
+
+	// the converted values can be used in scripts
+	env := NewBuiltinEnv()
+	scores, _ := FromGo(map[string]int{"alice": 90, "bob": 80})
+	env.Set("scores", scores)
+	ret, err := EvalAll(strToToken(`(cdr (assoc "bob" scores))`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(80), ret)
+}
+
+func TestNewSandboxEnv(t *testing.T) {
+	env := NewSandboxEnv()
+	_, err := EvalAll(strToToken(`(load "test.scm")`), env)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.EqualError(t, err, "load: file access is not allowed in sandbox")
+
+	_, err = EvalAll(strToToken(`(exit)`), env)
+	assert.EqualError(t, err, "exit: not allowed in sandbox")
+
+	ret, err := EvalAll(strToToken(`(define (f x) (* x 2)) (f 21)`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(42), ret)
+
+	// the other environments are not sandboxed
+	ret, err = EvalAll(strToToken(`(load "test.scm")`), NewBuiltinEnv())
+	assert.Nil(t, err)
+}
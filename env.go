@@ -3,18 +3,132 @@ package goscheme
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
+	"strings"
+	"sync"
+	"unicode"
 )
 
 // Env represents the context of code.
+// It is safe to find and set the symbols of Env concurrently, but the values such as pairs and hash tables are not
+// synchronized. The goroutines can evaluate in their own NewChildEnv of the shared global environment, so the
+// definitions of them do not affect each other. The context of EvalContext, the limit of EvalWithLimit and the
+// bindings of parameterize, with-exception-handler and with-output-to-string only apply to the evaluation which
+// sets them, while the output set by SetOutput is shared by the whole global environment.
 type Env struct {
+	mu    sync.RWMutex
 	outer *Env
 	frame map[Symbol]Expression
+	// state is shared by the environments extended from the same global environment
+	state *evalState
+	// dynamic is the state of the evaluation in progress, nil if not needed, see dynamicState
+	dynamic *dynamicState
+	// base is the environment whose frame is shared by this one, see view
+	base *Env
+	// aliases resolves the identifiers renamed by the macro expansions, see aliasScope
+	aliases *aliasScope
+}
+
+// String returns the external representation of the environment returned by interaction-environment.
+func (e *Env) String() string {
+	return "#<environment>"
+}
+
+// sandboxed checks whether the environment is created by NewSandboxEnv.
+func (e *Env) sandboxed() bool {
+	return e.state != nil && e.state.sandbox
+}
+
+// extendEnv returns a new empty environment enclosed by outer.
+func extendEnv(outer *Env) *Env {
+	return &Env{outer: outer, frame: make(map[Symbol]Expression), state: outer.state, dynamic: outer.dynamic,
+		aliases: outer.aliases}
+}
+
+// view returns a new environment sharing the bindings of e, whose dynamic state and aliases can be replaced.
+func (e *Env) view() *Env {
+	base := e
+	if e.base != nil {
+		base = e.base
+	}
+	return &Env{outer: e.outer, frame: e.frame, state: e.state, dynamic: e.dynamic, base: base, aliases: e.aliases}
+}
+
+// withDynamic returns the environment sharing the bindings of e, in which the expressions are evaluated with the
+// dynamic state d. It is used to evaluate in an environment captured before, e.g. the environment of a procedure,
+// as part of the evaluation of the caller.
+func (e *Env) withDynamic(d *dynamicState) *Env {
+	if e == nil {
+		// called outside of an evaluation, only the dynamic state is passed to the procedures
+		return &Env{dynamic: d}
+	}
+	if e.dynamic == d {
+		return e
+	}
+	view := e.view()
+	view.dynamic = d
+	return view
+}
+
+// dynamicState returns the dynamic state of the evaluation in e, the nil environment has none.
+func (e *Env) dynamicState() *dynamicState {
+	if e == nil {
+		return nil
+	}
+	return e.dynamic
+}
+
+// copyDynamic returns a copy of the dynamic state of the evaluation in e, which binds the new values for a nested
+// dynamic extent, see withDynamic.
+func (e *Env) copyDynamic() dynamicState {
+	if d := e.dynamicState(); d != nil {
+		return *d
+	}
+	return dynamicState{}
+}
+
+// frameLock returns the lock guarding the frame, which belongs to the base environment if any.
+func (e *Env) frameLock() *sync.RWMutex {
+	if e.base != nil {
+		return &e.base.mu
+	}
+	return &e.mu
+}
+
+// NewChildEnv returns a new empty environment enclosed by env, the symbols defined in it are invisible to env.
+func NewChildEnv(env *Env) *Env {
+	return extendEnv(env)
+}
+
+// lookup returns the value of the symbol bound in the frame of current environment.
+func (e *Env) lookup(symbol Symbol) (Expression, bool) {
+	e.frameLock().RLock()
+	defer e.frameLock().RUnlock()
+	ret, ok := e.frame[symbol]
+	return ret, ok
+}
+
+// assign sets the value of the symbol in the innermost environment binding it, returns false if unbound.
+func (e *Env) assign(symbol Symbol, value Expression) bool {
+	for current := e; current != nil; current = current.outer {
+		current.frameLock().Lock()
+		_, ok := current.frame[symbol]
+		if ok {
+			current.frame[symbol] = value
+		}
+		current.frameLock().Unlock()
+		if ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Find search all the relative environments to find the variable matching symbol.
 func (e *Env) Find(symbol Symbol) (Expression, error) {
-	ret, ok := e.frame[symbol]
+	ret, ok := e.lookup(symbol)
 	if ok {
 		return ret, nil
 	}
@@ -26,15 +140,19 @@ func (e *Env) Find(symbol Symbol) (Expression, error) {
 
 // Set a symbol and its value in current environment
 func (e *Env) Set(symbol Symbol, value Expression) {
+	e.frameLock().Lock()
+	defer e.frameLock().Unlock()
 	e.frame[symbol] = value
 }
 
 // Symbols returns the bound symbols including the outer frame
 func (e *Env) Symbols() []Symbol {
 	var ret []Symbol
+	e.frameLock().RLock()
 	for k := range e.frame {
 		ret = append(ret, k)
 	}
+	e.frameLock().RUnlock()
 	if e.outer != nil {
 		ret = append(ret, e.outer.Symbols()...)
 	}
@@ -58,153 +176,193 @@ func exitFunc(_ ...Expression) (Expression, error) {
 }
 
 func addFunc(args ...Expression) (Expression, error) {
-	var ret Number
+	var ret Expression = Integer(0)
 	for _, arg := range args {
 		num, err := expressionToNumber(arg)
 		if err != nil {
-			return 0, err
+			return UndefObj, err
 		}
-		ret = ret + num
+		ret = addNumbers(ret, num)
 	}
 	return ret, nil
 }
 
 func minusFunc(args ...Expression) (Expression, error) {
-	var ret Number
 	ret, err := expressionToNumber(args[0])
 	if err != nil {
 		return UndefObj, err
 	}
-	for i, arg := range args {
-		if i == 0 {
-			continue
-		}
+	if len(args) == 1 {
+		return subNumbers(Integer(0), ret), nil
+	}
+	for _, arg := range args[1:] {
 		num, err := expressionToNumber(arg)
 		if err != nil {
 			return UndefObj, err
 		}
-		ret = ret - num
+		ret = subNumbers(ret, num)
 	}
 	return ret, nil
 }
 
 func plusFunc(args ...Expression) (Expression, error) {
-	var ret Number = 1
+	var ret Expression = Integer(1)
 	for _, arg := range args {
 		num, err := expressionToNumber(arg)
 		if err != nil {
 			return UndefObj, err
 		}
-		ret = ret * num
+		ret = mulNumbers(ret, num)
 	}
 	return ret, nil
 }
 
 func divFunc(args ...Expression) (Expression, error) {
+	ret, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
 	if len(args) == 1 {
-		num, err := expressionToNumber(args[0])
-		if err != nil {
-			return UndefObj, err
-		}
-		return 1 / num, nil
+		return divNumbers(Integer(1), ret)
 	}
-	var ret Number
-	for i, arg := range args {
+	for _, arg := range args[1:] {
 		num, err := expressionToNumber(arg)
 		if err != nil {
 			return UndefObj, err
 		}
-		if i == 0 {
-			ret = num
-			continue
+		ret, err = divNumbers(ret, num)
+		if err != nil {
+			return UndefObj, err
 		}
-		ret = ret / num
 	}
 	return ret, nil
 }
 
-func eqlFunc(args ...Expression) (Expression, error) {
-	if args[0] == args[1] {
-		return true, nil
+// isEqv checks whether the two values are equivalent in the sense of eqv?.
+// Pointer values are compared by identity except the big numbers which are compared by value. The strings are
+// immutable values without identity, so the strings with the same characters are eqv?. The functions are identical
+// only if they are created by the same NewFunction call, e.g. two continuations are never eqv?.
+func isEqv(a, b Expression) bool {
+	switch x := a.(type) {
+	case *big.Int:
+		y, ok := b.(*big.Int)
+		return ok && x.Cmp(y) == 0
+	case *big.Rat:
+		y, ok := b.(*big.Rat)
+		return ok && x.Cmp(y) == 0
+	case Function:
+		y, ok := b.(Function)
+		return ok && x.id == y.id
+	case Number, Integer, Char, String, Quote, bool, NilType, Undef, *Pair, *Vector, *HashTable, *LambdaProcess,
+		*Thunk, *Syntax, *Continuation, *SyntaxRules, *Macro, EOFObject, *Port,
+		*ErrorObject, *Parameter, *Env:
+		return a == b
+	default:
+		return false
 	}
-	return false, nil
 }
 
-func lessFunc(args ...Expression) (Expression, error) {
-	op1, err := expressionToNumber(args[0])
-	if err != nil {
-		return UndefObj, err
-	}
-	op2, err := expressionToNumber(args[1])
-	if err != nil {
-		return UndefObj, err
-	}
-	if op1 < op2 {
-		return true, nil
+// isEq checks whether the two values are identical in the sense of eq?, which differs from eqv? in comparing
+// the big numbers by identity.
+func isEq(a, b Expression) bool {
+	switch a.(type) {
+	case *big.Int, *big.Rat:
+		return a == b
+	default:
+		return isEqv(a, b)
 	}
-	return false, nil
 }
 
-func greaterFunc(args ...Expression) (Expression, error) {
-	op1, err := expressionToNumber(args[0])
-	if err != nil {
-		return UndefObj, err
-	}
-	op2, err := expressionToNumber(args[1])
-	if err != nil {
-		return UndefObj, err
-	}
-	if op1 > op2 {
-		return true, nil
+// isEqual checks whether the two values are equal in the sense of equal?, pairs and vectors are compared
+// recursively by their elements.
+func isEqual(a, b Expression) bool {
+	switch x := a.(type) {
+	case *Pair:
+		y, ok := b.(*Pair)
+		return ok && isEqual(x.Car, y.Car) && isEqual(x.Cdr, y.Cdr)
+	case *Vector:
+		y, ok := b.(*Vector)
+		if !ok || len(x.Elements) != len(y.Elements) {
+			return false
+		}
+		for i := range x.Elements {
+			if !isEqual(x.Elements[i], y.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return isEqv(a, b)
 	}
-	return false, nil
 }
 
-func lessEqualFunc(args ...Expression) (Expression, error) {
-	op1, err := expressionToNumber(args[0])
-	if err != nil {
-		return UndefObj, err
-	}
-	op2, err := expressionToNumber(args[1])
-	if err != nil {
-		return UndefObj, err
+func isEqFunc(args ...Expression) (Expression, error) {
+	return isEq(args[0], args[1]), nil
+}
+
+func isEqvFunc(args ...Expression) (Expression, error) {
+	return isEqv(args[0], args[1]), nil
+}
+
+func isEqualFunc(args ...Expression) (Expression, error) {
+	return isEqual(args[0], args[1]), nil
+}
+
+func eqlFunc(args ...Expression) (Expression, error) {
+	for i := 0; i+1 < len(args); i++ {
+		if !IsNumber(args[i]) || !IsNumber(args[i+1]) {
+			if !isEqv(args[i], args[i+1]) {
+				return false, nil
+			}
+			continue
+		}
+		ret, err := compareOperands(args[i], args[i+1], func(c int) bool { return c == 0 })
+		if err != nil || ret == false {
+			return ret, err
+		}
 	}
-	if op1 <= op2 {
-		return true, nil
+	return true, nil
+}
+
+// compareChain checks every adjacent pair of the numbers with predicate, it stops at the first pair violating the
+// predicate.
+func compareChain(args []Expression, predicate func(int) bool) (Expression, error) {
+	for i := 0; i+1 < len(args); i++ {
+		ret, err := compareOperands(args[i], args[i+1], predicate)
+		if err != nil || ret == false {
+			return ret, err
+		}
 	}
-	return false, nil
+	return true, nil
 }
 
-func greatEqualFunc(args ...Expression) (Expression, error) {
-	op1, err := expressionToNumber(args[0])
+// compareOperands compares the two numbers and checks the comparison result with predicate.
+func compareOperands(arg1, arg2 Expression, predicate func(int) bool) (Expression, error) {
+	op1, err := expressionToNumber(arg1)
 	if err != nil {
 		return UndefObj, err
 	}
-	op2, err := expressionToNumber(args[1])
+	op2, err := expressionToNumber(arg2)
 	if err != nil {
 		return UndefObj, err
 	}
-	if op1 >= op2 {
-		return true, nil
-	}
-	return false, nil
+	return predicate(compareNumbers(op1, op2)), nil
 }
 
-func displayFunc(args ...Expression) (Expression, error) {
-	exp := args[0]
-	switch v := exp.(type) {
-	case String:
-		fmt.Print(string(v))
-	default:
-		fmt.Printf("%v", valueToString(v))
-	}
-	return UndefObj, nil
+func lessFunc(args ...Expression) (Expression, error) {
+	return compareChain(args, func(c int) bool { return c < 0 })
 }
 
-func displaylnFunc(args ...Expression) (Expression, error) {
-	ret, err := displayFunc(args...)
-	fmt.Println()
-	return ret, err
+func greaterFunc(args ...Expression) (Expression, error) {
+	return compareChain(args, func(c int) bool { return c > 0 })
+}
+
+func lessEqualFunc(args ...Expression) (Expression, error) {
+	return compareChain(args, func(c int) bool { return c <= 0 })
+}
+
+func greatEqualFunc(args ...Expression) (Expression, error) {
+	return compareChain(args, func(c int) bool { return c >= 0 })
 }
 
 func isNullFunc(args ...Expression) (Expression, error) {
@@ -216,10 +374,70 @@ func isStringFunc(args ...Expression) (Expression, error) {
 	return IsString(exp), nil
 }
 
+func isPairFunc(args ...Expression) (Expression, error) {
+	p, ok := args[0].(*Pair)
+	return ok && !p.IsNull(), nil
+}
+
+// isListFunc checks whether the argument is a proper list, it returns #f for the circular list.
+func isListFunc(args ...Expression) (Expression, error) {
+	return isList(args[0]), nil
+}
+
+func isSymbolFunc(args ...Expression) (Expression, error) {
+	return IsQuote(args[0]), nil
+}
+
+func isNumberFunc(args ...Expression) (Expression, error) {
+	switch args[0].(type) {
+	case Number, Integer, *big.Int, *big.Rat:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func isBooleanFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(bool)
+	return ok, nil
+}
+
+func isCharFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(Char)
+	return ok, nil
+}
+
+// isProcedureFunc checks whether the argument can be called, which are the builtin functions, the lambda processes,
+// the continuations and the parameters.
+func isProcedureFunc(args ...Expression) (Expression, error) {
+	switch args[0].(type) {
+	case Function, *LambdaProcess, *Parameter:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// notFunc returns #t only for #f, every other value including '(), 0 and "" is true.
 func notFunc(args ...Expression) (Expression, error) {
 	return !IsTrue(args[0]), nil
 }
 
+// booleanEqualFunc checks whether the booleans are all #t or all #f.
+func booleanEqualFunc(args ...Expression) (Expression, error) {
+	for _, arg := range args {
+		if _, ok := arg.(bool); !ok {
+			return UndefObj, fmt.Errorf("boolean=?: %v is not a boolean", valueToString(arg))
+		}
+	}
+	for _, arg := range args[1:] {
+		if arg != args[0] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // concatFunc concat the strings
 func concatFunc(args ...Expression) (Expression, error) {
 	var ret String
@@ -238,61 +456,328 @@ func checkThunkFunc(args ...Expression) (Expression, error) {
 	return IsThunk(args[0]), nil
 }
 
-func forceFunc(args ...Expression) (Expression, error) {
-	return ActualValue(args[0])
+// makePromiseFunc returns the forced promise of the value, a promise is returned as is.
+func makePromiseFunc(args ...Expression) (Expression, error) {
+	if t, ok := args[0].(*Thunk); ok {
+		return t, nil
+	}
+	return &Thunk{Exp: args[0], ret: args[0]}, nil
+}
+
+// forceFunc returns the value of the promise, the value is calculated only once. A non promise value is returned
+// unchanged.
+func forceFunc(env *Env, args ...Expression) (Expression, error) {
+	return actualValue(env, args[0])
+}
+
+// callCCFunc calls the procedure with the current continuation, see Continuation.
+func callCCFunc(env *Env, args ...Expression) (ret Expression, err error) {
+	k := &Continuation{}
+	defer func() {
+		k.expired = true
+		if r := recover(); r != nil {
+			invoked, ok := r.(*continuationInvoked)
+			if !ok || invoked.k != k {
+				panic(r)
+			}
+			ret, err = invoked.value, nil
+		}
+	}()
+	return callProcedure(env, args[0], NewFunction("continuation", k.Invoke, -1, -1))
+}
+
+// valuesFunc returns the arguments as multiple values, a single value is returned as is.
+func valuesFunc(args ...Expression) (Expression, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return Values(args), nil
+}
+
+// callWithValuesFunc calls the consumer with the values returned by the producer as arguments.
+func callWithValuesFunc(env *Env, args ...Expression) (Expression, error) {
+	ret, err := callProcedure(env, args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	if values, ok := ret.(Values); ok {
+		return callProcedure(env, args[1], values...)
+	}
+	return callProcedure(env, args[1], ret)
+}
+
+// gensymFunc returns a fresh symbol, the optional argument is the prefix of the symbol.
+func gensymFunc(args ...Expression) (Expression, error) {
+	prefix := "g"
+	if len(args) == 1 {
+		switch v := args[0].(type) {
+		case String:
+			prefix = string(v)
+		case Quote:
+			prefix = string(v)
+		default:
+			return UndefObj, fmt.Errorf("argument %v is not a string or symbol", v)
+		}
+	}
+	return Quote(gensym(prefix)), nil
 }
 
 var builtinFunctions = map[Symbol]Function{
-	"exit":      NewFunction("exit", exitFunc, 0, 0),
-	"+":         NewFunction("+", addFunc, 1, -1),
-	"-":         NewFunction("-", minusFunc, 1, -1),
-	"*":         NewFunction("*", plusFunc, 1, -1),
-	"/":         NewFunction("/", divFunc, 1, -1),
-	"=":         NewFunction("=", eqlFunc, 2, 2),
-	"<":         NewFunction("<", lessFunc, 2, 2),
-	">":         NewFunction(">", greaterFunc, 2, 2),
-	"<=":        NewFunction("<=", lessEqualFunc, 2, 2),
-	">=":        NewFunction(">=", greatEqualFunc, 2, 2),
-	"display":   NewFunction("display", displayFunc, 1, 1),
-	"displayln": NewFunction("displayln", displaylnFunc, 1, 1),
-	"null?":     NewFunction("null?", isNullFunc, 1, 1),
-	"string?":   NewFunction("string?", isStringFunc, 1, 1),
-	"not":       NewFunction("not", notFunc, 1, 1),
+	"exit":    NewFunction("exit", exitFunc, 0, 0),
+	"+":       NewFunction("+", addFunc, 1, -1),
+	"-":       NewFunction("-", minusFunc, 1, -1),
+	"*":       NewFunction("*", plusFunc, 1, -1),
+	"/":       NewFunction("/", divFunc, 1, -1),
+	"=":       NewFunction("=", eqlFunc, 2, -1),
+	"<":       NewFunction("<", lessFunc, 2, -1),
+	">":       NewFunction(">", greaterFunc, 2, -1),
+	"<=":      NewFunction("<=", lessEqualFunc, 2, -1),
+	">=":      NewFunction(">=", greatEqualFunc, 2, -1),
+	"null?":   NewFunction("null?", isNullFunc, 1, 1),
+	"string?": NewFunction("string?", isStringFunc, 1, 1),
+	"not":     NewFunction("not", notFunc, 1, 1),
 	//"and":       NewFunction("and", andFunc, 1, -1),
 	//"or":        NewFunction("or", orFunc, 1, -1),
 	"cons":     NewFunction("cons", consImpl, 2, 2),
 	"car":      NewFunction("car", carImpl, 1, 1),
 	"cdr":      NewFunction("cdr", cdrImpl, 1, 1),
 	"list":     NewFunction("list", listImpl, -1, -1),
-	"append":   NewFunction("append", appendImpl, 2, -1),
+	"append":   NewFunction("append", appendImpl, -1, -1),
 	"set-car!": NewFunction("set-car!", setCarImpl, 2, 2),
 	"set-cdr!": NewFunction("set-cdr!", setCdrImpl, 2, 2),
 	"concat":   NewFunction("concat", concatFunc, 2, -1),
 	"thunk?":   NewFunction("thunk?", checkThunkFunc, 1, 1),
-	"force":    NewFunction("thunk?", forceFunc, 1, 1),
-}
-
+	"force":    newEnvFunction("force", forceFunc, 1, 1),
+	"promise?": NewFunction("promise?", checkThunkFunc, 1, 1),
+	"gensym":   NewFunction("gensym", gensymFunc, 0, 1),
+
+	// promises
+	"make-promise": NewFunction("make-promise", makePromiseFunc, 1, 1),
+
+	// streams
+	"stream-car":    NewFunction("stream-car", streamCarFunc, 1, 1),
+	"stream-cdr":    newEnvFunction("stream-cdr", streamCdrFunc, 1, 1),
+	"stream-ref":    newEnvFunction("stream-ref", streamRefFunc, 2, 2),
+	"stream-take":   newEnvFunction("stream-take", streamTakeFunc, 2, 2),
+	"stream-map":    newEnvFunction("stream-map", streamMapFunc, 2, -1),
+	"stream-filter": newEnvFunction("stream-filter", streamFilterFunc, 2, 2),
+
+	// higher-order list procedures
+	"map":       newEnvFunction("map", mapFunc, 2, -1),
+	"for-each":  newEnvFunction("for-each", forEachFunc, 2, -1),
+	"filter":    newEnvFunction("filter", filterFunc, 2, 2),
+	"remove":    newEnvFunction("remove", removeFunc, 2, 2),
+	"partition": newEnvFunction("partition", partitionFunc, 2, 2),
+	"sort":      newEnvFunction("sort", sortFunc, 2, 2),
+	"length":    NewFunction("length", lengthFunc, 1, 1),
+	"reverse":   NewFunction("reverse", reverseFunc, 1, 1),
+	"list-ref":  NewFunction("list-ref", listRefFunc, 2, 2),
+	"list-tail": NewFunction("list-tail", listTailFunc, 2, 2),
+	"iota":      NewFunction("iota", iotaFunc, 1, 3),
+
+	// list utilities
+	"cons*":      NewFunction("cons*", consStarFunc, 1, -1),
+	"list*":      NewFunction("list*", consStarFunc, 1, -1),
+	"make-list":  NewFunction("make-list", makeListFunc, 1, 2),
+	"list-copy":  NewFunction("list-copy", listCopyFunc, 1, 1),
+	"last-pair":  NewFunction("last-pair", lastPairFunc, 1, 1),
+	"last":       NewFunction("last", lastFunc, 1, 1),
+	"take":       NewFunction("take", takeFunc, 2, 2),
+	"drop":       NewFunction("drop", dropFunc, 2, 2),
+	"list-index": newEnvFunction("list-index", listIndexFunc, 2, -1),
+
+	// association lists
+	"assq":  NewFunction("assq", assqFunc, 2, 2),
+	"assv":  NewFunction("assv", assvFunc, 2, 2),
+	"assoc": NewFunction("assoc", assocFunc, 2, 2),
+
+	// membership
+	"memq":   NewFunction("memq", memqFunc, 2, 2),
+	"memv":   NewFunction("memv", memvFunc, 2, 2),
+	"member": NewFunction("member", memberFunc, 2, 2),
+
+	// equivalence predicates
+	"eq?":    NewFunction("eq?", isEqFunc, 2, 2),
+	"eqv?":   NewFunction("eqv?", isEqvFunc, 2, 2),
+	"equal?": NewFunction("equal?", isEqualFunc, 2, 2),
+
+	// type predicates
+	"pair?":      NewFunction("pair?", isPairFunc, 1, 1),
+	"list?":      NewFunction("list?", isListFunc, 1, 1),
+	"symbol?":    NewFunction("symbol?", isSymbolFunc, 1, 1),
+	"number?":    NewFunction("number?", isNumberFunc, 1, 1),
+	"boolean?":   NewFunction("boolean?", isBooleanFunc, 1, 1),
+	"char?":      NewFunction("char?", isCharFunc, 1, 1),
+	"procedure?": NewFunction("procedure?", isProcedureFunc, 1, 1),
+
+	// numeric types, there are no complex numbers so the real and complex numbers are all the numbers
+	"integer?":  NewFunction("integer?", isIntegerFunc, 1, 1),
+	"rational?": NewFunction("rational?", isRationalFunc, 1, 1),
+	"real?":     NewFunction("real?", isNumberFunc, 1, 1),
+	"complex?":  NewFunction("complex?", isNumberFunc, 1, 1),
+
+	// booleans
+	"boolean=?": NewFunction("boolean=?", booleanEqualFunc, 2, -1),
+
+	// continuations
+	"call/cc":                        newEnvFunction("call/cc", callCCFunc, 1, 1),
+	"call-with-current-continuation": newEnvFunction("call-with-current-continuation", callCCFunc, 1, 1),
+	"dynamic-wind":                   newEnvFunction("dynamic-wind", dynamicWindFunc, 3, 3),
+
+	// parameters
+	"make-parameter": newEnvFunction("make-parameter", makeParameterFunc, 1, 2),
+
+	// multiple values
+	"values":           NewFunction("values", valuesFunc, -1, -1),
+	"call-with-values": newEnvFunction("call-with-values", callWithValuesFunc, 2, 2),
+
+	// exactness
+	"exact?":         NewFunction("exact?", isExactFunc, 1, 1),
+	"inexact?":       NewFunction("inexact?", isInexactFunc, 1, 1),
+	"exact-integer?": NewFunction("exact-integer?", isExactIntegerFunc, 1, 1),
+	"exact->inexact": NewFunction("exact->inexact", exactToInexactFunc, 1, 1),
+	"inexact->exact": NewFunction("inexact->exact", inexactToExactFunc, 1, 1),
+	"expt":           NewFunction("expt", exptFunc, 2, 2),
+	"abs":            NewFunction("abs", absFunc, 1, 1),
+	"sqrt":           NewFunction("sqrt", sqrtFunc, 1, 1),
+
+	// transcendental functions
+	"exp":         NewFunction("exp", floatFunction(math.Exp), 1, 1),
+	"log":         NewFunction("log", logFunc, 1, 2),
+	"sin":         NewFunction("sin", floatFunction(math.Sin), 1, 1),
+	"cos":         NewFunction("cos", floatFunction(math.Cos), 1, 1),
+	"tan":         NewFunction("tan", floatFunction(math.Tan), 1, 1),
+	"asin":        NewFunction("asin", floatFunction(math.Asin), 1, 1),
+	"acos":        NewFunction("acos", floatFunction(math.Acos), 1, 1),
+	"atan":        NewFunction("atan", atanFunc, 1, 2),
+	"numerator":   NewFunction("numerator", numeratorFunc, 1, 1),
+	"denominator": NewFunction("denominator", denominatorFunc, 1, 1),
+
+	// integer division
+	"quotient":  NewFunction("quotient", quotientFunc, 2, 2),
+	"remainder": NewFunction("remainder", remainderFunc, 2, 2),
+	"modulo":    NewFunction("modulo", moduloFunc, 2, 2),
+	"floor/":    NewFunction("floor/", floorDivFunc, 2, 2),
+	"truncate/": NewFunction("truncate/", truncateDivFunc, 2, 2),
+	"max":       NewFunction("max", maxFunc, 1, -1),
+	"min":       NewFunction("min", minFunc, 1, -1),
+	"gcd":       NewFunction("gcd", gcdFunc, -1, -1),
+	"lcm":       NewFunction("lcm", lcmFunc, -1, -1),
+
+	// numeric predicates
+	"zero?":     NewFunction("zero?", signPredicate(0), 1, 1),
+	"positive?": NewFunction("positive?", signPredicate(1), 1, 1),
+	"negative?": NewFunction("negative?", signPredicate(-1), 1, 1),
+	"odd?":      NewFunction("odd?", parityPredicate("odd?", 1), 1, 1),
+	"even?":     NewFunction("even?", parityPredicate("even?", 0), 1, 1),
+
+	// rounding
+	"floor":    NewFunction("floor", floorFunc, 1, 1),
+	"ceiling":  NewFunction("ceiling", ceilingFunc, 1, 1),
+	"round":    NewFunction("round", roundFunc, 1, 1),
+	"truncate": NewFunction("truncate", truncateFunc, 1, 1),
+
+	// characters
+	"char->integer":    NewFunction("char->integer", charToIntegerFunc, 1, 1),
+	"integer->char":    NewFunction("integer->char", integerToCharFunc, 1, 1),
+	"char=?":           NewFunction("char=?", charEqualFunc, 2, -1),
+	"char<?":           NewFunction("char<?", charLessFunc, 2, -1),
+	"char-upcase":      NewFunction("char-upcase", charUpcaseFunc, 1, 1),
+	"char-downcase":    NewFunction("char-downcase", charDowncaseFunc, 1, 1),
+	"char-alphabetic?": NewFunction("char-alphabetic?", charClassifier(unicode.IsLetter), 1, 1),
+	"char-numeric?":    NewFunction("char-numeric?", charClassifier(unicode.IsDigit), 1, 1),
+	"char-whitespace?": NewFunction("char-whitespace?", charClassifier(unicode.IsSpace), 1, 1),
+	"char-upper-case?": NewFunction("char-upper-case?", charClassifier(unicode.IsUpper), 1, 1),
+	"char-lower-case?": NewFunction("char-lower-case?", charClassifier(unicode.IsLower), 1, 1),
+
+	// strings
+	"string-append":  NewFunction("string-append", stringAppendFunc, -1, -1),
+	"substring":      NewFunction("substring", substringFunc, 2, 3),
+	"string-length":  NewFunction("string-length", stringLengthFunc, 1, 1),
+	"string-ref":     NewFunction("string-ref", stringRefFunc, 2, 2),
+	"string->list":   NewFunction("string->list", stringToListFunc, 1, 1),
+	"list->string":   NewFunction("list->string", listToStringFunc, 1, 1),
+	"number->string": NewFunction("number->string", numberToStringFunc, 1, 2),
+	"string->number": NewFunction("string->number", stringToNumberFunc, 1, 2),
+
+	// string utilities
+	"string-upcase":   NewFunction("string-upcase", stringMapper(strings.ToUpper), 1, 1),
+	"string-downcase": NewFunction("string-downcase", stringMapper(strings.ToLower), 1, 1),
+	"string-split":    NewFunction("string-split", stringSplitFunc, 2, 2),
+	"string-join":     NewFunction("string-join", stringJoinFunc, 1, 2),
+	"string-contains": NewFunction("string-contains", stringContainsFunc, 2, 2),
+	"string-index":    newEnvFunction("string-index", stringIndexFunc, 2, 2),
+
+	// string comparisons
+	"string=?":     NewFunction("string=?", stringComparator(false, func(c int) bool { return c == 0 }), 2, -1),
+	"string<?":     NewFunction("string<?", stringComparator(false, func(c int) bool { return c < 0 }), 2, -1),
+	"string>?":     NewFunction("string>?", stringComparator(false, func(c int) bool { return c > 0 }), 2, -1),
+	"string<=?":    NewFunction("string<=?", stringComparator(false, func(c int) bool { return c <= 0 }), 2, -1),
+	"string>=?":    NewFunction("string>=?", stringComparator(false, func(c int) bool { return c >= 0 }), 2, -1),
+	"string-ci=?":  NewFunction("string-ci=?", stringComparator(true, func(c int) bool { return c == 0 }), 2, -1),
+	"string-ci<?":  NewFunction("string-ci<?", stringComparator(true, func(c int) bool { return c < 0 }), 2, -1),
+	"string-ci>?":  NewFunction("string-ci>?", stringComparator(true, func(c int) bool { return c > 0 }), 2, -1),
+	"string-ci<=?": NewFunction("string-ci<=?", stringComparator(true, func(c int) bool { return c <= 0 }), 2, -1),
+	"string-ci>=?": NewFunction("string-ci>=?", stringComparator(true, func(c int) bool { return c >= 0 }), 2, -1),
+
+	// vectors
+	"vector?":       NewFunction("vector?", isVectorFunc, 1, 1),
+	"make-vector":   NewFunction("make-vector", makeVectorFunc, 1, 2),
+	"vector":        NewFunction("vector", vectorFunc, -1, -1),
+	"vector-ref":    NewFunction("vector-ref", vectorRefFunc, 2, 2),
+	"vector-set!":   NewFunction("vector-set!", vectorSetFunc, 3, 3),
+	"vector-length": NewFunction("vector-length", vectorLengthFunc, 1, 1),
+	"vector->list":  NewFunction("vector->list", vectorToListFunc, 1, 1),
+	"list->vector":  NewFunction("list->vector", listToVectorFunc, 1, 1),
+	"vector-fill!":  NewFunction("vector-fill!", vectorFillFunc, 2, 2),
+
+	// hash tables
+	"make-hash-table":    NewFunction("make-hash-table", makeHashTableFunc, 0, 0),
+	"hash-table?":        NewFunction("hash-table?", isHashTableFunc, 1, 1),
+	"hash-table-set!":    NewFunction("hash-table-set!", hashTableSetFunc, 3, 3),
+	"hash-table-ref":     NewFunction("hash-table-ref", hashTableRefFunc, 2, 3),
+	"hash-table-delete!": NewFunction("hash-table-delete!", hashTableDeleteFunc, 2, 2),
+	"hash-table-count":   NewFunction("hash-table-count", hashTableCountFunc, 1, 1),
+	"hash-table-keys":    NewFunction("hash-table-keys", hashTableKeysFunc, 1, 1),
+	"hash-table-values":  NewFunction("hash-table-values", hashTableValuesFunc, 1, 1),
+
+	// ports
+	"eof-object":         NewFunction("eof-object", eofObjectFunc, 0, 0),
+	"eof-object?":        NewFunction("eof-object?", isEOFObjectFunc, 1, 1),
+	"port?":              NewFunction("port?", isPortFunc, 1, 1),
+	"input-port?":        NewFunction("input-port?", isInputPortFunc, 1, 1),
+	"output-port?":       NewFunction("output-port?", isOutputPortFunc, 1, 1),
+	"open-input-string":  NewFunction("open-input-string", openInputStringFunc, 1, 1),
+	"open-output-string": NewFunction("open-output-string", openOutputStringFunc, 0, 0),
+	"get-output-string":  NewFunction("get-output-string", getOutputStringFunc, 1, 1),
+	"open-input-file":    NewFunction("open-input-file", openInputFileFunc, 1, 1),
+	"close-port":         NewFunction("close-port", closePortFunc, 1, 1),
+
+	// errors
+	"error":                  NewFunction("error", errorFunc, 1, -1),
+	"raise":                  NewFunction("raise", raiseFunc, 1, 1),
+	"error-object?":          NewFunction("error-object?", isErrorObjectFunc, 1, 1),
+	"error-object-message":   NewFunction("error-object-message", errorObjectMessageFunc, 1, 1),
+	"error-object-irritants": NewFunction("error-object-irritants", errorObjectIrritantsFunc, 1, 1),
+}
+
+// setCarImpl replaces the car of the pair in place, the change is visible through every reference to the pair.
 func setCarImpl(args ...Expression) (Expression, error) {
-	exp := args[0]
-	newValue := args[1]
-	switch p := exp.(type) {
-	case *Pair:
-		p.Car = newValue
-	default:
-		return UndefObj, fmt.Errorf("%v is not a pair", exp)
+	p, ok := args[0].(*Pair)
+	if !ok {
+		return UndefObj, fmt.Errorf("set-car!: %v is not a pair", valueToString(args[0]))
 	}
+	p.Car = args[1]
 	return UndefObj, nil
 }
 
+// setCdrImpl replaces the cdr of the pair in place, the change is visible through every reference to the pair.
 func setCdrImpl(args ...Expression) (Expression, error) {
-	exp := args[0]
-	newValue := args[1]
-	switch p := exp.(type) {
-	case *Pair:
-		p.Cdr = newValue
-	default:
-		return UndefObj, fmt.Errorf("%v is not a pair", exp)
+	p, ok := args[0].(*Pair)
+	if !ok {
+		return UndefObj, fmt.Errorf("set-cdr!: %v is not a pair", valueToString(args[0]))
 	}
+	p.Cdr = args[1]
 	return UndefObj, nil
 }
 
@@ -331,41 +816,23 @@ func cdrImpl(args ...Expression) (Expression, error) {
 	}
 }
 
-func appendImpl(args ...Expression) (ret Expression, err error) {
-	ret = args[0]
-	for i := 1; i < len(args); i++ {
-		ret, err = merge(ret, args[i])
-		if err != nil {
-			return UndefObj, err
-		}
-	}
-	return ret, nil
-}
-
-// append arg2 to arg1 and return the new *pair
-func merge(arg1, arg2 Expression) (Expression, error) {
-	if !isList(arg1) {
-		return UndefObj, errors.New("not a list")
+// appendImpl returns a new list of the elements of all the lists, the last argument is shared and can be any
+// object, e.g. (append '(1) 2) returns (1 . 2).
+func appendImpl(args ...Expression) (Expression, error) {
+	if len(args) == 0 {
+		return NilObj, nil
 	}
-	if IsNullExp(arg1) {
-		if isList(arg2) {
-			return arg2, nil
+	ret := args[len(args)-1]
+	for i := len(args) - 2; i >= 0; i-- {
+		if !isList(args[i]) {
+			return UndefObj, fmt.Errorf("append: %v is not a list", valueToString(args[i]))
+		}
+		elements := extractList(args[i])
+		for j := len(elements) - 1; j >= 0; j-- {
+			ret = &Pair{elements[j], ret}
 		}
-		return listImpl(arg2)
-	}
-	rest, err := cdrImpl(arg1)
-	if err != nil {
-		return UndefObj, err
-	}
-	newArg, err := merge(rest, arg2)
-	if err != nil {
-		return UndefObj, err
-	}
-	car, err := carImpl(arg1)
-	if err != nil {
-		return NilObj, err
 	}
-	return consImpl(car, newArg)
+	return ret, nil
 }
 
 func isList(exp Expression) bool {
@@ -380,10 +847,11 @@ func isList(exp Expression) bool {
 }
 
 func setupBuiltinEnv() *Env {
-	initSyntax()
+	syntaxOnce.Do(initSyntax)
 	var builtinEnv = &Env{
 		outer: nil,
 		frame: make(map[Symbol]Expression),
+		state: &evalState{},
 	}
 	for key, syntax := range SyntaxMap {
 		builtinEnv.Set(Symbol(key), syntax)
@@ -391,44 +859,56 @@ func setupBuiltinEnv() *Env {
 	for k, fn := range builtinFunctions {
 		builtinEnv.Set(k, fn)
 	}
+	for k, fn := range pairAccessors() {
+		builtinEnv.Set(k, fn)
+	}
+	for k, fn := range outputFunctions(builtinEnv) {
+		builtinEnv.Set(k, fn)
+	}
+	for k, fn := range inputFunctions(builtinEnv) {
+		builtinEnv.Set(k, fn)
+	}
+	for k, fn := range exceptionFunctions() {
+		builtinEnv.Set(k, fn)
+	}
+	for k, fn := range environmentFunctions(builtinEnv) {
+		builtinEnv.Set(k, fn)
+	}
 	loadBuiltinProcedures(builtinEnv)
 	return builtinEnv
 }
 
-const builtinProcedures = `
-(define (map procedure list-arguments)
-	(cond
-		((null? list-arguments) '())
-		(else 
-			(cons (procedure (car list-arguments)) 
-					(map procedure 
-							(cdr list-arguments))))))
-
-(define (filter predicate sequence)
-  (cond ((null? sequence) '())
-        ((predicate (car sequence))
-         (cons (car sequence)
-               (filter predicate (cdr sequence))))
-        (else (filter predicate (cdr sequence)))))
+// environmentFunctions returns the procedures returning the environments passed to eval, which are derived from
+// the global environment env.
+func environmentFunctions(env *Env) map[Symbol]Function {
+	return map[Symbol]Function{
+		"interaction-environment": NewFunction("interaction-environment", func(_ ...Expression) (Expression, error) {
+			return env, nil
+		}, 0, 0),
+		"null-environment": NewFunction("null-environment", func(args ...Expression) (Expression, error) {
+			if len(args) > 0 && args[0] != Integer(5) {
+				return UndefObj, fmt.Errorf("null-environment: version %v is not supported", valueToString(args[0]))
+			}
+			return nullEnvironment(env), nil
+		}, 0, 1),
+	}
+}
 
+// nullEnvironment returns a new global environment binding only the syntax, it shares the state of env.
+func nullEnvironment(env *Env) *Env {
+	nullEnv := &Env{frame: make(map[Symbol]Expression), state: env.state}
+	for key, syntax := range SyntaxMap {
+		nullEnv.Set(Symbol(key), syntax)
+	}
+	return nullEnv
+}
+
+const builtinProcedures = `
 (define (reduce proc items)
   (if (null? items)
       0
       (proc (car items) (reduce proc (cdr items)))))
 
-(define (remainder a b)
-  (if (< a b)
-      a
-      (remainder (- a b) b)))
-
-(define list-ref
-    (lambda (lst place)
-      (if (null? lst)
-          '()
-          (if (= place 0)
-          (car lst)
-          (list-ref (cdr lst) (- place 1))))))
-
 (define (list-set! list k val)
     (if (= k 0)
         (set-car! list val)
@@ -1,7 +1,9 @@
 package goscheme
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -50,12 +52,101 @@ func Test_appendImpl(t *testing.T) {
 		input    []Expression
 		expected *Pair
 	}{
-		{[]Expression{&Pair{1, NilObj}, 2}, &Pair{1, &Pair{2, NilObj}}},
+		{[]Expression{&Pair{1, NilObj}, 2}, &Pair{1, 2}},
 		{[]Expression{&Pair{1, NilObj}, &Pair{2, NilObj}}, &Pair{1, &Pair{2, NilObj}}},
-		{[]Expression{&Pair{1, NilObj}, &Pair{2, NilObj}, 3}, &Pair{1, &Pair{2, &Pair{3, NilObj}}}},
+		{[]Expression{&Pair{1, NilObj}, &Pair{2, NilObj}, 3}, &Pair{1, &Pair{2, 3}}},
+		{[]Expression{&Pair{1, NilObj}, NilObj, &Pair{2, &Pair{3, NilObj}}}, &Pair{1, &Pair{2, &Pair{3, NilObj}}}},
 	}
 	for _, c := range testCases {
 		l, _ := appendImpl(c.input...)
 		assert.Equal(t, c.expected, l)
 	}
 }
+
+func TestEquivalencePredicates(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"(eq? 'a 'a)", true},
+		{"(eq? '() '())", true},
+		{"(eq? #t #t)", true},
+		{"(eq? 1 1)", true},
+		{"(eq? '(1) '(1))", false},
+		{"(eq? car car)", true},
+		{"(eq? car cdr)", false},
+		{"(define x '(1)) (eq? x x)", true},
+		{"(eq? 100000000000000000000 100000000000000000000)", false},
+		{"(eqv? 100000000000000000000 100000000000000000000)", true},
+		{"(eqv? 1/2 2/4)", true},
+		{"(eqv? 1 1.0)", false},
+		{`(eqv? #\a #\a)`, true},
+		{"(eqv? (vector 1) (vector 1))", false},
+		{`(eqv? "ab" (string-append "a" "b"))`, true},
+		{"(call/cc (lambda (k1) (call/cc (lambda (k2) (eqv? k1 k2)))))", false},
+		{"(call/cc (lambda (k) (eqv? k k)))", true},
+		{"(equal? '(1 2 (3)) '(1 2 (3)))", true},
+		{"(equal? '(1 2 (3)) '(1 2 (4)))", false},
+		{"(equal? '(1 2) '(1 2 3))", false},
+		{`(equal? "abc" "abc")`, true},
+		{"(equal? #(1 (2) #(3)) (vector 1 '(2) #(3)))", true},
+		{"(equal? #(1 2) #(1 2 3))", false},
+		{"(equal? 1 1.0)", false},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestEnvConcurrency(t *testing.T) {
+	global := NewBuiltinEnv()
+	_, err := EvalAll(strToToken(`(define counter 0) (define (square x) (* x x))`), global)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]Expression, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := NewChildEnv(global)
+			env.Set("n", Integer(i))
+			ret, err := EvalAll(strToToken(`(define local (square n)) (set! counter (+ counter 1)) local`), env)
+			assert.Nil(t, err)
+			results[i] = ret
+		}(i)
+	}
+	wg.Wait()
+	for i, ret := range results {
+		assert.Equal(t, Integer(i*i), ret)
+	}
+	// the definitions of the children are invisible to the global environment
+	_, err = global.Find("local")
+	assert.NotNil(t, err)
+	counter, _ := global.Find("counter")
+	assert.IsType(t, Integer(0), counter)
+
+	// the limits and the output ports bound by an evaluation do not affect the others
+	_, err = EvalAll(strToToken(`(define (loop) (loop))`), global)
+	assert.Nil(t, err)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := NewChildEnv(global)
+			env.Set("n", Integer(i))
+			_, err := EvalWithLimit(strToToken(`(loop)`)[0], env, uint64(1000*(i+1)))
+			assert.EqualError(t, err, "step limit exceeded")
+			ret, err := EvalAll(strToToken(`(with-output-to-string (lambda () (display (square n))))`), env)
+			assert.Nil(t, err)
+			results[i] = ret
+		}(i)
+	}
+	wg.Wait()
+	for i, ret := range results {
+		assert.Equal(t, String(fmt.Sprint(i*i)), ret)
+	}
+}
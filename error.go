@@ -0,0 +1,293 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxErrorStackDepth limits the number of enclosing expressions recorded in the SchemeError.
+const maxErrorStackDepth = 16
+
+// SchemeError represents the error raised when evaluating the scheme expressions.
+type SchemeError struct {
+	Message string
+	// Expression is the innermost expression whose evaluation failed.
+	Expression Expression
+	// Stack records the enclosing expressions being evaluated when the error raised, the innermost first.
+	// The expressions in tail position are not recorded since they replace their callers.
+	Stack []Expression
+	// Line and Column is the source position of Expression or the innermost expression of Stack, zero if unknown,
+	// see Positions.Locate.
+	Line, Column int
+	// Condition is the value raised by the scheme code, e.g. the *ErrorObject raised by error, nil if the error is
+	// raised by the interpreter.
+	Condition Expression
+	// cause is the original error converted to the SchemeError
+	cause error
+}
+
+// Error returns the message of the error with the source position if known.
+func (e *SchemeError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+	}
+	return e.Message
+}
+
+// Unwrap returns the original error converted to the SchemeError, e.g. the error of context.
+func (e *SchemeError) Unwrap() error {
+	return e.cause
+}
+
+// StackTrace returns the expressions in the stack, one expression per line.
+func (e *SchemeError) StackTrace() string {
+	lines := make([]string, 0, len(e.Stack)+1)
+	lines = append(lines, expToPrintString(e.Expression))
+	for _, exp := range e.Stack {
+		lines = append(lines, expToPrintString(exp))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ErrorObject is the condition raised by error and assert, which carries the message and the irritants.
+// Should only use with pointer.
+type ErrorObject struct {
+	Message   string
+	Irritants []Expression
+}
+
+// String returns the message followed by the irritants, e.g. "message: irritant1 irritant2".
+func (e *ErrorObject) String() string {
+	if len(e.Irritants) == 0 {
+		return e.Message
+	}
+	s := make([]string, len(e.Irritants))
+	for i, irritant := range e.Irritants {
+		s[i] = writeString(irritant)
+	}
+	return e.Message + ": " + strings.Join(s, " ")
+}
+
+// raiseError returns the *SchemeError raising the error object.
+func raiseError(message string, irritants ...Expression) *SchemeError {
+	condition := &ErrorObject{message, irritants}
+	return &SchemeError{Message: condition.String(), Condition: condition}
+}
+
+// errorFunc raises the error object with the message and the irritants.
+func errorFunc(args ...Expression) (Expression, error) {
+	return UndefObj, raiseError(displayString(args[0]), args[1:]...)
+}
+
+func expressionToErrorObject(exp Expression) (*ErrorObject, error) {
+	e, ok := exp.(*ErrorObject)
+	if !ok {
+		return nil, fmt.Errorf("%v is not an error object", valueToString(exp))
+	}
+	return e, nil
+}
+
+func isErrorObjectFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(*ErrorObject)
+	return ok, nil
+}
+
+func errorObjectMessageFunc(args ...Expression) (Expression, error) {
+	e, err := expressionToErrorObject(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return String(e.Message), nil
+}
+
+func errorObjectIrritantsFunc(args ...Expression) (Expression, error) {
+	e, err := expressionToErrorObject(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(e.Irritants...)
+}
+
+// condition returns the value raised by the scheme code, the errors raised by the interpreter are converted to the
+// error objects with the messages.
+func (e *SchemeError) condition() Expression {
+	if e.Condition != nil {
+		return e.Condition
+	}
+	return &ErrorObject{Message: e.Message}
+}
+
+// raiseFunc raises the value as the condition which can be caught by guard and with-exception-handler.
+func raiseFunc(args ...Expression) (Expression, error) {
+	if e, ok := args[0].(*ErrorObject); ok {
+		return UndefObj, &SchemeError{Message: e.String(), Condition: e}
+	}
+	return UndefObj, &SchemeError{Message: "uncaught exception: " + writeString(args[0]), Condition: args[0]}
+}
+
+// evalGuard evaluates the body, the condition raised is bound to the variable to evaluate the cond clauses.
+// The condition is raised again if no clause matches. The errors aborting the evaluation are not caught, see isAbort.
+// (guard (var clause ...) body ...)
+func evalGuard(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("guard: syntax error (guard should pass the clauses and body)")
+	}
+	spec, ok := args[0].([]Expression)
+	if !ok || len(spec) < 1 {
+		return UndefObj, errors.New("guard: syntax error (not a valid variable and clauses)")
+	}
+	variable, err := transExpressionToSymbol(spec[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	ret, err := EvalSafe(sequenceToExp(args[1:]), env)
+	if err == nil || isAbort(err) {
+		return ret, err
+	}
+	se := err.(*SchemeError)
+	clauses := append([]Expression{"cond"}, spec[1:]...)
+	if len(spec) == 1 || !isElseClause(spec[len(spec)-1]) {
+		reraise := NewFunction("raise", func(_ ...Expression) (Expression, error) {
+			return UndefObj, se
+		}, 0, 0)
+		clauses = append(clauses, []Expression{"else", []Expression{reraise}})
+	}
+	handler, err := makeLambdaProcess([]Symbol{variable}, []Expression{clauses}, env)
+	if err != nil {
+		return UndefObj, err
+	}
+	return []Expression{handler, se.condition()}, nil
+}
+
+// exceptionFunctions returns the procedures installing and calling the exception handlers of the caller.
+func exceptionFunctions() map[Symbol]Function {
+	return map[Symbol]Function{
+		"with-exception-handler": newEnvFunction("with-exception-handler", func(env *Env, args ...Expression) (Expression, error) {
+			return withExceptionHandler(env, args[0], args[1])
+		}, 2, 2),
+		"raise-continuable": newEnvFunction("raise-continuable", func(env *Env, args ...Expression) (Expression, error) {
+			return raiseContinuable(env, args[0])
+		}, 1, 1),
+	}
+}
+
+// withExceptionHandler calls the thunk with the handler installed. The handler is called with the condition
+// raised by raise-continuable and its value is returned to raise-continuable. The other conditions unwind the
+// thunk before calling the handler, then they are raised again if the handler returns. The errors aborting the
+// evaluation are not passed to the handler, see isAbort. The handler is only installed for the evaluation of the
+// thunk, so it is uninstalled even if the thunk escapes through a continuation.
+func withExceptionHandler(env *Env, handler, thunk Expression) (Expression, error) {
+	d := env.copyDynamic()
+	d.handlers = &handlerBinding{handler: handler, next: d.handlers}
+	ret, err := callProcedureSafe(env.withDynamic(&d), thunk)
+	if err == nil || isAbort(err) {
+		return ret, err
+	}
+	se := err.(*SchemeError)
+	if _, err := callProcedure(env, handler, se.condition()); err != nil {
+		return UndefObj, err
+	}
+	return UndefObj, se
+}
+
+// handlerBinding is the exception handler installed by with-exception-handler, the handlers form a list from the
+// current handler to the outermost.
+type handlerBinding struct {
+	handler Expression
+	next    *handlerBinding
+}
+
+// raiseContinuable calls the current exception handler with the condition and returns the value of the handler.
+// The handler is called with the outer handlers installed.
+func raiseContinuable(env *Env, condition Expression) (Expression, error) {
+	d := env.copyDynamic()
+	if d.handlers == nil {
+		return raiseFunc(condition)
+	}
+	handler := d.handlers.handler
+	d.handlers = d.handlers.next
+	return callProcedure(env.withDynamic(&d), handler, condition)
+}
+
+// callProcedureSafe calls the procedure like callProcedure, but the panics are recovered and returned as
+// *SchemeError, see EvalSafe.
+func callProcedureSafe(env *Env, fn Expression, args ...Expression) (ret Expression, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*continuationInvoked); ok {
+				panic(r)
+			}
+			ret, err = UndefObj, newSchemeError(r)
+		}
+	}()
+	ret, err = callProcedure(env, fn, args...)
+	if err != nil {
+		return UndefObj, newSchemeError(err)
+	}
+	return ret, nil
+}
+
+// evalAssert raises the error object with the failed expression if the expression is false.
+func evalAssert(args []Expression, env *Env) (Expression, error) {
+	if len(args) != 1 {
+		return UndefObj, errors.New("assert: syntax error (requires 1 argument)")
+	}
+	val, err := Eval(args[0], env)
+	if err != nil {
+		return UndefObj, err
+	}
+	if IsTrue(val) {
+		return UndefObj, nil
+	}
+	datum, err := evalQuote(args, env)
+	if err != nil {
+		return UndefObj, err
+	}
+	return UndefObj, raiseError("assertion failed", datum)
+}
+
+// newSchemeError converts the error or the recovered panic value to *SchemeError.
+func newSchemeError(reason interface{}) *SchemeError {
+	switch v := reason.(type) {
+	case *SchemeError:
+		return v
+	case error:
+		return &SchemeError{Message: v.Error(), cause: v}
+	default:
+		return &SchemeError{Message: fmt.Sprintf("%v", v)}
+	}
+}
+
+// wrapError records the expression in the error when the error propagates out of the evaluation of exp.
+func wrapError(err error, exp Expression) *SchemeError {
+	se, ok := err.(*SchemeError)
+	if !ok {
+		se = &SchemeError{Message: err.Error(), cause: err}
+	}
+	if se.Expression == nil {
+		se.Expression = exp
+	} else if len(se.Stack) < maxErrorStackDepth {
+		se.Stack = append(se.Stack, exp)
+	}
+	return se
+}
+
+// EvalSafe evaluates the expression like Eval, but never panics. The errors and panics raised during evaluation
+// are returned as *SchemeError. The host code embedding the interpreter should prefer this entry point.
+func EvalSafe(exp Expression, env *Env) (ret Expression, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			// the escape of continuation should unwind to the call/cc
+			if _, ok := r.(*continuationInvoked); ok {
+				panic(r)
+			}
+			ret, err = UndefObj, newSchemeError(r)
+		}
+	}()
+	ret, err = Eval(exp, env)
+	if err != nil {
+		return UndefObj, newSchemeError(err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,159 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestEvalSafe(t *testing.T) {
+	env := setupBuiltinEnv()
+	env.Set("boom", NewFunction("boom", func(args ...Expression) (Expression, error) {
+		panic("boom")
+	}, 0, 0))
+
+	ret, err := EvalSafe(strToToken("(+ 1 2)")[0], env)
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(3), ret)
+
+	_, err = EvalSafe(strToToken("(boom)")[0], env)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.EqualError(t, err, "boom")
+
+	_, err = EvalSafe(strToToken("(car 1)")[0], env)
+	assert.IsType(t, &SchemeError{}, err)
+	assert.EqualError(t, err, "argument is not a pair")
+}
+
+func TestSchemeError(t *testing.T) {
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(define (f x) (+ 1 (car x))) (f 1)"), env)
+	se, ok := err.(*SchemeError)
+	assert.True(t, ok)
+	assert.Equal(t, "argument is not a pair", se.Message)
+	assert.Equal(t, "(car x)", expToPrintString(se.Expression))
+	// the call (f 1) is replaced by the body of f in tail position
+	assert.Equal(t, "(car x)\n(+ 1 (car x))", se.StackTrace())
+
+	_, err = EvalAll(strToToken("(+ 1 undefined-symbol)"), env)
+	se, ok = err.(*SchemeError)
+	assert.True(t, ok)
+	assert.Equal(t, "undefined-symbol", se.Expression)
+	assert.Equal(t, 1, len(se.Stack))
+
+	assert.EqualError(t, &SchemeError{Message: "failed", Line: 12, Column: 3}, "failed at line 12, column 3")
+}
+
+func TestSchemeErrorPosition(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(define x 1)\n  (car x)", "argument is not a pair at line 2, column 3"},
+		{"(define (f x)\n  (+ 1\n     (car x)))\n(f 1)", "argument is not a pair at line 3, column 6"},
+		{"\n'(1 2)\n(undefined-symbol)", "symbol undefined-symbol unbound at line 3, column 1"},
+	}
+	for _, c := range testCases {
+		tokens, positions := NewTokenizerFromString(c.input).TokensWithPositions()
+		exps, table, err := ParseWithPositions(&tokens, positions)
+		assert.Nil(t, err)
+		_, err = EvalAll(exps, setupBuiltinEnv())
+		assert.EqualError(t, table.Locate(err), c.expected)
+	}
+}
+
+func TestErrorAndAssert(t *testing.T) {
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(error "something bad")`, "something bad"},
+		{`(error "bad values" 1 "two" 'three '(4))`, `bad values: 1 "two" three (4)`},
+		{`(error 'my-proc "failed")`, `my-proc: "failed"`},
+		{`(define x -1) (assert (> x 0))`, "assertion failed: (> x 0)"},
+		{`(define (f x) (error "f failed" x)) (+ 1 (f 2))`, "f failed: 2"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.IsType(t, &SchemeError{}, err, c.input)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(error "bad values" 1 "two")`), NewBuiltinEnv())
+	condition := err.(*SchemeError).Condition
+	assert.Equal(t, &ErrorObject{"bad values", []Expression{Integer(1), String("two")}}, condition)
+
+	// the interpreter errors have no condition
+	_, err = EvalAll(strToToken(`(car 1)`), NewBuiltinEnv())
+	assert.Nil(t, err.(*SchemeError).Condition)
+
+	ret, err := EvalAll(strToToken(`(assert (= 1 1))`), NewBuiltinEnv())
+	assert.Nil(t, err)
+	assert.Equal(t, UndefObj, ret)
+}
+
+func TestGuardAndExceptionHandler(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(guard (e (#t (error-object-message e))) (error "boom" 1))`, String("boom")},
+		{`(guard (e ((eq? e 'oops) (list 'caught e))) (raise 'oops))`, "(caught oops)"},
+		{`(guard (e ((string? e) 1) ((exact? e) (* e 2))) (raise 21))`, Integer(42)},
+		{`(guard (e ((assq 'a e) => cdr) ((assq 'b e))) (raise (list (cons 'a 42))))`, Integer(42)},
+		{`(guard (e (else 'other)) (+ 1 (car 1)))`, "other"},
+		{`(guard (e (#t (error-object? e))) (car 1))`, true},
+		{`(guard (e (#t 0)) (+ 1 2))`, Integer(3)},
+		{`(guard (e ((string? e) 'outer)) (guard (e ((vector? e) 'inner)) (raise "s")))`, "outer"},
+		{`(with-exception-handler (lambda (e) 42) (lambda () (+ (raise-continuable 'c) 1)))`, Integer(43)},
+		{`(call/cc (lambda (k) (with-exception-handler (lambda (e) (k (list 'handled e))) (lambda () (raise 'boom)))))`, "(handled boom)"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		if s, ok := c.expected.(string); ok {
+			assert.Equal(t, s, valueToString(ret), c.input)
+		} else {
+			assert.Equal(t, c.expected, ret, c.input)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(guard (e ((string? e) 'caught)) (raise 42))`, "uncaught exception: 42"},
+		{`(guard (e ((string? e) 'caught)) (error "not caught"))`, "not caught"},
+		{`(raise-continuable 'c)`, "uncaught exception: c"},
+		{`(with-exception-handler (lambda (e) 0) (lambda () (raise 'boom)))`, "uncaught exception: boom"},
+		// the handler is uninstalled when the thunk escapes through a continuation
+		{`(call/cc (lambda (k) (with-exception-handler (lambda (e) 0) (lambda () (k 1)))))
+		  (raise-continuable 'x)`, "uncaught exception: x"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestExceptionHandlerConcurrency(t *testing.T) {
+	global := NewBuiltinEnv()
+	_, err := EvalAll(strToToken(`(define (check n k)
+		(cond ((= k 0) #t) ((= (raise-continuable 'ask) n) (check n (- k 1))) (else #f)))`), global)
+	assert.Nil(t, err)
+
+	// the handlers installed by an evaluation are invisible to the others
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := NewChildEnv(global)
+			env.Set("n", Integer(i))
+			ret, err := EvalAll(strToToken(`(with-exception-handler (lambda (c) n) (lambda () (check n 1000)))`), env)
+			assert.Nil(t, err)
+			assert.Equal(t, true, ret)
+		}(i)
+	}
+	wg.Wait()
+}
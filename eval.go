@@ -3,21 +3,35 @@ package goscheme
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"path"
-	"regexp"
-	"strconv"
 )
 
 // Eval is the main function to evaluate the expression in an environment.
+// The error returned is a *SchemeError recording the expression failed.
 func Eval(exp Expression, env *Env) (ret Expression, err error) {
+	defer func() {
+		if err != nil {
+			err = wrapError(err, exp)
+		}
+	}()
 	for {
+		if err := env.dynamic.step(); err != nil {
+			return UndefObj, err
+		}
 		if IsPrimitiveExpression(exp) {
 			return evalPrimitive(exp)
 		}
 		if IsSymbol(exp) {
 			s, _ := exp.(string)
-			ret, err = env.Find(Symbol(s))
+			ret, err = lookupSymbol(Symbol(s), env)
+			if err != nil {
+				return UndefObj, err
+			}
+			if ret == unassignedObj {
+				return UndefObj, fmt.Errorf("symbol %v used before its initialization", s)
+			}
 			return
 		}
 		if IsSyntaxExpression(exp) {
@@ -35,7 +49,18 @@ func Eval(exp Expression, env *Env) (ret Expression, err error) {
 			if !ok {
 				return UndefObj, fmt.Errorf("%s is not a valid expression", exp)
 			}
-			nextExp, newEnv, err := applyCallable(ops[0], ops[1:], env)
+			fn, err := Eval(ops[0], env)
+			if err != nil {
+				return UndefObj, err
+			}
+			if macro, ok := fn.(expander); ok {
+				exp, env, err = macro.Expand(ops, env)
+				if err != nil {
+					return UndefObj, err
+				}
+				continue
+			}
+			nextExp, newEnv, err := applyCallable(fn, ops[1:], env)
 			if err != nil {
 				return UndefObj, err
 			}
@@ -47,11 +72,7 @@ func Eval(exp Expression, env *Env) (ret Expression, err error) {
 
 // for tail recursion optimization, return the next expression will be executed and the new environment to execute the
 // next loop in eval
-func applyCallable(process Expression, argExpressions []Expression, env *Env) (Expression, *Env, error) {
-	fn, err := Eval(process, env)
-	if err != nil {
-		return fn, env, err
-	}
+func applyCallable(fn Expression, argExpressions []Expression, env *Env) (Expression, *Env, error) {
 	switch p := fn.(type) {
 	case Function:
 		var args []Expression
@@ -62,26 +83,54 @@ func applyCallable(process Expression, argExpressions []Expression, env *Env) (E
 			}
 			args = append(args, v)
 		}
-		ret, err := p.Call(args...)
+		ret, err := p.call(env, args...)
 		return ret, env, err
 	case *LambdaProcess:
-		newEnv := &Env{outer: p.env, frame: make(map[Symbol]Expression)}
-		if len(argExpressions) != len(p.params) {
-			return UndefObj, env, errors.New(fmt.Sprintf("%v\n", p.String()) + "require " + strconv.Itoa(len(p.params)) + " but " + strconv.Itoa(len(argExpressions)) + " provide")
-		}
-		for i, arg := range argExpressions {
+		var args []Expression
+		for _, arg := range argExpressions {
 			val, err := Eval(arg, env)
 			if err != nil {
 				return UndefObj, env, err
 			}
-			newEnv.Set(p.params[i], val)
+			args = append(args, val)
+		}
+		newEnv, err := p.bindArguments(args, env)
+		if err != nil {
+			return UndefObj, env, err
 		}
 		return p.Body(), newEnv, nil
+	case *Parameter:
+		if len(argExpressions) != 0 {
+			return UndefObj, env, errors.New("parameter: expected 0 arguments")
+		}
+		return p.valueIn(env), env, nil
 	default:
 		return UndefObj, env, fmt.Errorf("%v is not callable", fn)
 	}
 }
 
+// callProcedure calls the procedure with the evaluated arguments and returns the result. The procedure is called
+// as part of the evaluation in env, which may be nil if there is none.
+func callProcedure(env *Env, fn Expression, args ...Expression) (Expression, error) {
+	switch p := fn.(type) {
+	case Function:
+		return p.call(env, args...)
+	case *LambdaProcess:
+		newEnv, err := p.bindArguments(args, env)
+		if err != nil {
+			return UndefObj, err
+		}
+		return Eval(p.Body(), newEnv)
+	case *Parameter:
+		if len(args) != 0 {
+			return UndefObj, errors.New("parameter: expected 0 arguments")
+		}
+		return p.valueIn(env), nil
+	default:
+		return UndefObj, fmt.Errorf("%v is not callable", valueToString(fn))
+	}
+}
+
 func applySyntaxExpression(syntax *Syntax, args []Expression, env *Env) (Expression, error) {
 	return syntax.Eval(args, env)
 }
@@ -112,9 +161,16 @@ func evalPrimitive(exp Expression) (Expression, error) {
 	if IsString(exp) {
 		return expToString(exp)
 	}
+	if IsChar(exp) {
+		return expressionToChar(exp)
+	}
+	if v, ok := exp.(vectorLiteral); ok {
+		return v.datum()
+	}
 	return exp, nil
 }
 
+// evalSet updates the value of the symbol in the nearest environment which defines it.
 func evalSet(args []Expression, env *Env) (Expression, error) {
 	if len(args) != 2 {
 		return UndefObj, errors.New("set!: syntax error (set! requires variable and value arguments)")
@@ -128,18 +184,19 @@ func evalSet(args []Expression, env *Env) (Expression, error) {
 	if err != nil {
 		return UndefObj, err
 	}
-	currentEnv := env
-	for currentEnv != nil {
-		if _, ok := currentEnv.frame[sym]; ok {
-			currentEnv.Set(sym, val)
+	// the identifier renamed by macro expansion falls back to the original one, see lookupSymbol
+	for name, target, renamed := sym, env, true; renamed; name, target, renamed = target.resolveAlias(name) {
+		if target.assign(name, val) {
 			return UndefObj, nil
 		}
-		currentEnv = env.outer
 	}
-	return UndefObj, fmt.Errorf("variable %v cannot set! before define", sym)
+	return UndefObj, fmt.Errorf("set!: cannot set undefined variable: %v", sym)
 }
 
-func evalLetRec(args []Expression, env *Env) (Expression, error) {
+// evalLetrec binds all the symbols in a new environment before evaluating the init expressions, so the init
+// expressions can refer to each other recursively. The init expressions are evaluated from left to right, so it
+// implements letrec* as well.
+func evalLetrec(args []Expression, env *Env) (Expression, error) {
 	if len(args) < 2 {
 		return UndefObj, errors.New("letrec: syntax error (letrec should pass the variables and body)")
 	}
@@ -147,41 +204,30 @@ func evalLetRec(args []Expression, env *Env) (Expression, error) {
 	if !ok {
 		return UndefObj, errors.New("letrec: syntax error (not a valid binding)")
 	}
-	newEnv := &Env{outer: env, frame: make(map[Symbol]Expression)}
-	// init symbols with undef
-	for _, exp := range bindings {
-		binding, ok := exp.([]Expression)
-		if !ok || len(binding) != 2 {
-			return UndefObj, errors.New("letrec: syntax error (not a valid binding)")
-		}
-		sym, err := transExpressionToSymbol(binding[0])
-		if err != nil {
-			return UndefObj, err
-		}
-		newEnv.Set(sym, UndefObj)
+	params, inits, err := splitLetBindings("letrec", bindings)
+	if err != nil {
+		return UndefObj, err
 	}
-	// set value for symbols
-	for _, exp := range bindings {
-		binding, _ := exp.([]Expression)
-		sym, _ := transExpressionToSymbol(binding[0])
-		val, err := Eval(binding[1], newEnv)
+	newEnv := extendEnv(env)
+	for _, sym := range params {
+		newEnv.Set(sym, unassignedObj)
+	}
+	for i, sym := range params {
+		val, err := Eval(inits[i], newEnv)
 		if err != nil {
 			return UndefObj, err
 		}
 		newEnv.Set(sym, val)
 	}
-	var ret Expression
-	var err error
-	for _, exp := range args[1:] {
-		ret, err = Eval(exp, newEnv)
-		if err != nil {
-			return ret, err
-		}
+	process, err := makeLambdaProcess(nil, args[1:], newEnv)
+	if err != nil {
+		return UndefObj, err
 	}
-	return ret, nil
+	return []Expression{process}, nil
 }
 
-func evalL2RLet(args []Expression, env *Env) (Expression, error) {
+// evalLetStar expands the let* expression into nested let expressions, each binding is visible to the later ones.
+func evalLetStar(args []Expression, env *Env) (Expression, error) {
 	if len(args) < 2 {
 		return UndefObj, errors.New("let*: syntax error (let* should pass the variables and body)")
 	}
@@ -189,76 +235,86 @@ func evalL2RLet(args []Expression, env *Env) (Expression, error) {
 	if !ok {
 		return UndefObj, errors.New("let*: syntax error (not a valid binding)")
 	}
-	var outerEnv, currentEnv *Env
-	outerEnv = env
-	for _, exp := range bindings {
-		currentEnv = &Env{outer: outerEnv, frame: make(map[Symbol]Expression)}
-		binding, ok := exp.([]Expression)
-		if !ok || len(binding) != 2 {
-			return UndefObj, errors.New("let*: syntax error (not a valid binding)")
-		}
-		sym, err := transExpressionToSymbol(binding[0])
-		if err != nil {
-			return UndefObj, err
-		}
-		val, err := Eval(binding[1], currentEnv)
-		if err != nil {
-			return UndefObj, nil
-		}
-		currentEnv.Set(sym, val)
-		outerEnv = currentEnv
-	}
-	var ret Expression
-	var err error
-	for _, exp := range args[1:] {
-		ret, err = Eval(exp, currentEnv)
-		if err != nil {
-			return ret, err
-		}
+	if len(bindings) <= 1 {
+		return append([]Expression{"let", bindings}, args[1:]...), nil
 	}
-	return ret, nil
+	inner := append([]Expression{"let*", bindings[1:]}, args[1:]...)
+	return []Expression{"let", []Expression{bindings[0]}, inner}, nil
 }
 
+// evalLet transforms the let expression into the application of an anonymous lambda process, so the body is
+// evaluated in tail position like any other procedure call.
 func evalLet(args []Expression, env *Env) (Expression, error) {
 	if len(args) < 2 {
 		return UndefObj, errors.New("let: syntax error (let should pass the variables and body)")
 	}
+	if IsSymbol(args[0]) {
+		return evalNamedLet(args, env)
+	}
 	bindings, ok := args[0].([]Expression)
 	if !ok {
 		return UndefObj, errors.New("let: syntax error (not a valid binding)")
 	}
-	newEnv := &Env{outer: env, frame: make(map[Symbol]Expression)}
+	params, inits, err := splitLetBindings("let", bindings)
+	if err != nil {
+		return UndefObj, err
+	}
+	process, err := makeLambdaProcess(params, args[1:], env)
+	if err != nil {
+		return UndefObj, err
+	}
+	ret := []Expression{process}
+	return append(ret, inits...), nil
+}
+
+// evalNamedLet binds the lambda process to the name in a new environment so the body can call itself to iterate.
+func evalNamedLet(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 3 {
+		return UndefObj, errors.New("let: syntax error (named let should pass the name, variables and body)")
+	}
+	name, _ := transExpressionToSymbol(args[0])
+	bindings, ok := args[1].([]Expression)
+	if !ok {
+		return UndefObj, errors.New("let: syntax error (not a valid binding)")
+	}
+	params, inits, err := splitLetBindings("let", bindings)
+	if err != nil {
+		return UndefObj, err
+	}
+	newEnv := extendEnv(env)
+	process, err := makeLambdaProcess(params, args[2:], newEnv)
+	if err != nil {
+		return UndefObj, err
+	}
+	newEnv.Set(name, process)
+	ret := []Expression{process}
+	return append(ret, inits...), nil
+}
+
+// splitLetBindings returns the symbols and the init expressions of the let bindings.
+func splitLetBindings(syntaxName string, bindings []Expression) (params []Symbol, inits []Expression, err error) {
 	for _, exp := range bindings {
 		binding, ok := exp.([]Expression)
 		if !ok || len(binding) != 2 {
-			return UndefObj, errors.New("let: syntax error (not a valid binding)")
+			return nil, nil, fmt.Errorf("%s: syntax error (not a valid binding)", syntaxName)
 		}
 		sym, err := transExpressionToSymbol(binding[0])
 		if err != nil {
-			return UndefObj, err
-		}
-		val, err := Eval(binding[1], env)
-		if err != nil {
-			return UndefObj, nil
-		}
-		newEnv.Set(sym, val)
-	}
-	var ret Expression
-	var err error
-	for _, exp := range args[1:] {
-		ret, err = Eval(exp, newEnv)
-		if err != nil {
-			return ret, err
+			return nil, nil, err
 		}
+		params = append(params, sym)
+		inits = append(inits, binding[1])
 	}
-	return ret, nil
+	return
 }
 
+// evalAnd evaluates the operands from left to right until one is false, the last operand is returned to evaluate
+// in tail position. (and) is true.
 func evalAnd(args []Expression, env *Env) (Expression, error) {
-	if len(args) < 1 {
-		return UndefObj, errors.New("and require at least 1 argument")
+	if len(args) == 0 {
+		return true, nil
 	}
-	for _, e := range args {
+	for _, e := range args[:len(args)-1] {
 		val, err := Eval(e, env)
 		if err != nil {
 			return UndefObj, err
@@ -267,25 +323,30 @@ func evalAnd(args []Expression, env *Env) (Expression, error) {
 			return false, nil
 		}
 	}
-	return true, nil
+	return args[len(args)-1], nil
 }
 
+// evalOr evaluates the operands from left to right until one is true and returns its value, the last operand is
+// returned to evaluate in tail position. (or) is false.
 func evalOr(args []Expression, env *Env) (Expression, error) {
-	if len(args) < 1 {
-		return UndefObj, errors.New("or require at least 1 argument")
+	if len(args) == 0 {
+		return false, nil
 	}
-	for _, e := range args {
+	for _, e := range args[:len(args)-1] {
 		result, err := Eval(e, env)
 		if err != nil {
 			return UndefObj, err
 		}
 		if IsTrue(result) {
-			return true, nil
+			return result, nil
 		}
 	}
-	return false, nil
+	return args[len(args)-1], nil
 }
 
+// evalDelay creates the promise of the expression, it is also used by delay-force since forcing the promise
+// whose expression evaluates to another promise already continues with the nested promise iteratively, see
+// Thunk.Value.
 func evalDelay(args []Expression, env *Env) (Expression, error) {
 	if len(args) == 0 {
 		return UndefObj, errors.New("delay require 1 argument")
@@ -296,12 +357,11 @@ func evalDelay(args []Expression, env *Env) (Expression, error) {
 func expToString(exp Expression) (String, error) {
 	switch s := exp.(type) {
 	case string:
-		pattern := regexp.MustCompile(`"((.|[\r\n])*?)"`)
-		m := pattern.FindAllStringSubmatch(s, -1)
-		if len(m) < 1 || len(m[0]) < 2 {
+		if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
 			return "", errors.New("not a string, format invalid")
 		}
-		return String(m[0][1]), nil
+		// the escape sequences are replaced by the tokenizer
+		return String(s[1 : len(s)-1]), nil
 	case String:
 		return s, nil
 	default:
@@ -309,64 +369,111 @@ func expToString(exp Expression) (String, error) {
 	}
 }
 
-// evalEval eval the scheme object and calculate its value
+// evalEval evaluates the datum as an expression in the environment given by the optional second argument, which
+// defaults to the current environment. The datum is converted to the syntax tree directly rather than printed and
+// parsed again, see datumToExpression.
 func evalEval(args []Expression, env *Env) (Expression, error) {
-	if len(args) != 1 {
-		return UndefObj, errors.New("syntax error (requires 1 argument)")
+	if len(args) != 1 && len(args) != 2 {
+		return UndefObj, errors.New("eval: syntax error (requires 1 or 2 arguments)")
+	}
+	datum, err := Eval(args[0], env)
+	if err != nil {
+		return UndefObj, err
 	}
-	expression := args[0]
-	arg, err := Eval(expression, env)
+	exp, err := datumToExpression(datum)
 	if err != nil {
 		return UndefObj, err
 	}
-	if !validEvalExp(arg) {
-		return UndefObj, errors.New("error: malformed list")
+	if len(args) == 1 {
+		return exp, nil
 	}
-	expStr := valueToString(arg)
-	t := NewTokenizerFromString(expStr)
-	tokens := t.Tokens()
-	var ret []Expression
-	ret, err = Parse(&tokens)
+	v, err := Eval(args[1], env)
 	if err != nil {
 		return UndefObj, err
 	}
-	return EvalAll(ret, env)
+	target, ok := v.(*Env)
+	if !ok {
+		return UndefObj, fmt.Errorf("eval: %v is not an environment", valueToString(v))
+	}
+	return Eval(exp, target.withDynamic(env.dynamic))
 }
 
-func validEvalExp(exp Expression) bool {
-	switch p := exp.(type) {
+// datumToExpression converts the datum into the syntax tree evaluated by Eval in the form returned by Parse: the lists
+// become []Expression, the improper list (a . b) keeps the "." before its tail like the reader and the symbols
+// become the tokens, the other values evaluate to themselves. The quoted data are not converted.
+func datumToExpression(datum Expression) (Expression, error) {
+	switch v := datum.(type) {
+	case Quote:
+		return string(v), nil
+	case NilType:
+		return []Expression{}, nil
 	case *Pair:
-		if !p.IsList() {
-			return false
+		if q, ok := v.Car.(Quote); ok && q == "quote" {
+			// keep the quoted datum as it is, which may be an improper list
+			if !isList(v.Cdr) || len(extractList(v.Cdr)) != 1 {
+				return UndefObj, fmt.Errorf("eval: %v is not a valid quote expression", valueToString(v))
+			}
+			return []Expression{"quote", v.Cdr.(*Pair).Car}, nil
+		}
+		exps := []Expression{}
+		var rest Expression = v
+		slow := v
+		for n := 1; ; n++ {
+			p, ok := rest.(*Pair)
+			if !ok || p.IsNull() {
+				break
+			}
+			exp, err := datumToExpression(p.Car)
+			if err != nil {
+				return UndefObj, err
+			}
+			exps = append(exps, exp)
+			rest = p.Cdr
+			if n%2 == 0 {
+				if slow = slow.Cdr.(*Pair); Expression(slow) == rest {
+					return UndefObj, errors.New("eval: circular list")
+				}
+			}
+		}
+		if _, ok := rest.(NilType); !ok {
+			tail, err := datumToExpression(rest)
+			if err != nil {
+				return UndefObj, err
+			}
+			exps = append(exps, ".", tail)
 		}
-		return validEvalExp(p.Car) && validEvalExp(p.Cdr)
+		return exps, nil
 	default:
-		return true
+		return v, nil
 	}
 }
 
+// evalApply calls the procedure with the arguments, the leading arguments are prepended to the elements of the
+// last argument which must be a list. The call is returned to be evaluated in tail position.
 func evalApply(args []Expression, env *Env) (Expression, error) {
-	if len(args) != 2 {
-		return UndefObj, errors.New("syntax error (requires 2 argument)")
+	if len(args) < 2 {
+		return UndefObj, errors.New("apply: syntax error (requires at least 2 arguments)")
 	}
 	procedure, err := Eval(args[0], env)
 	if err != nil {
-		return UndefObj, nil
+		return UndefObj, err
+	}
+	expression := []Expression{procedure}
+	for _, arg := range args[1 : len(args)-1] {
+		v, err := Eval(arg, env)
+		if err != nil {
+			return UndefObj, err
+		}
+		expression = append(expression, v)
 	}
-	arg, err := Eval(args[1], env)
+	last, err := Eval(args[len(args)-1], env)
 	if err != nil {
-		return UndefObj, nil
+		return UndefObj, err
 	}
-	if !isList(arg) {
-		return UndefObj, errors.New("argument must be a list")
+	if !isList(last) {
+		return UndefObj, fmt.Errorf("apply: last argument %v is not a list", valueToString(last))
 	}
-	argList := arg.(*Pair)
-	var argSlice = make([]Expression, 0, 1)
-	argSlice = append(argSlice, extractList(argList)...)
-	var expression []Expression
-	expression = append(expression, procedure)
-	expression = append(expression, argSlice...)
-	return Eval(expression, env)
+	return append(expression, extractList(last)...), nil
 }
 
 // load other scheme script files
@@ -404,6 +511,9 @@ func evalLoad(expression []Expression, env *Env) (Expression, error) {
 }
 
 func loadFile(filePath string, env *Env) error {
+	if env.sandboxed() {
+		return errors.New("load: file access is not allowed in sandbox")
+	}
 	ext := path.Ext(filePath)
 	if ext != ".scm" {
 		filePath += ".scm"
@@ -422,7 +532,7 @@ func evalQuote(args []Expression, env *Env) (Expression, error) {
 	}
 	exp := args[0]
 	switch v := exp.(type) {
-	case Number:
+	case Number, Integer, *big.Int, *big.Rat:
 		return v, nil
 	case string:
 		if IsNumber(v) {
@@ -431,30 +541,125 @@ func evalQuote(args []Expression, env *Env) (Expression, error) {
 		if IsString(exp) {
 			return expToString(exp)
 		}
+		if IsChar(exp) {
+			return expressionToChar(exp)
+		}
+		if IsBoolean(exp) {
+			return IsTrue(exp), nil
+		}
 		return Quote(v), nil
 	case []Expression:
-		var args []Expression
-		for _, exp := range v {
-			q, err := evalQuote([]Expression{exp}, env)
+		// the dotted datum (a b . c) ends with the improper tail c
+		var ret Expression = NilObj
+		if n := len(v); n >= 3 && v[n-2] == "." {
+			tail, err := evalQuote([]Expression{v[n-1]}, env)
+			if err != nil {
+				return UndefObj, err
+			}
+			ret, v = tail, v[:n-2]
+		}
+		for i := len(v) - 1; i >= 0; i-- {
+			q, err := evalQuote([]Expression{v[i]}, env)
 			if err != nil {
 				return UndefObj, err
 			}
-			args = append(args, q)
+			ret = &Pair{q, ret}
 		}
-		return listImpl(args...)
+		return ret, nil
+	case vectorLiteral:
+		return v.datum()
 	default:
-		return UndefObj, errors.New("invalid quote argument")
+		// the datum built at runtime and passed to eval is already a value
+		return v, nil
+	}
+}
+
+// evalQuasiquote quotes the template like quote except the unquote and unquote-splicing expressions,
+// which are evaluated and inserted into the result.
+func evalQuasiquote(args []Expression, env *Env) (Expression, error) {
+	if len(args) != 1 {
+		return UndefObj, errors.New("syntax error (requires 1 argument)")
 	}
+	return quasiquoteTemplate(args[0], 1, env)
+}
+
+// quasiquoteTemplate walks the template, only the unquote expressions matching the depth of nested quasiquote
+// are evaluated.
+func quasiquoteTemplate(template Expression, depth int, env *Env) (Expression, error) {
+	exps, ok := template.([]Expression)
+	if !ok {
+		return evalQuote([]Expression{template}, env)
+	}
+	if len(exps) == 2 {
+		switch exps[0] {
+		case "unquote":
+			if depth == 1 {
+				return Eval(exps[1], env)
+			}
+			return quasiquoteWrap("unquote", exps[1], depth-1, env)
+		case "unquote-splicing":
+			// the splicing at depth 1 is handled by the enclosing list
+			if depth > 1 {
+				return quasiquoteWrap("unquote-splicing", exps[1], depth-1, env)
+			}
+		case "quasiquote":
+			return quasiquoteWrap("quasiquote", exps[1], depth+1, env)
+		}
+	}
+	// the dotted template (a . b) ends with the improper tail b
+	var tail Expression = NilObj
+	if n := len(exps); n >= 3 && exps[n-2] == "." {
+		var err error
+		if tail, err = quasiquoteTemplate(exps[n-1], depth, env); err != nil {
+			return UndefObj, err
+		}
+		exps = exps[:n-2]
+	}
+	var items []Expression
+	for _, exp := range exps {
+		if e, ok := exp.([]Expression); ok && len(e) == 2 && e[0] == "unquote-splicing" && depth == 1 {
+			val, err := Eval(e[1], env)
+			if err != nil {
+				return UndefObj, err
+			}
+			if !isList(val) {
+				return UndefObj, fmt.Errorf("unquote-splicing: %v is not a list", valueToString(val))
+			}
+			items = append(items, extractList(val)...)
+			continue
+		}
+		item, err := quasiquoteTemplate(exp, depth, env)
+		if err != nil {
+			return UndefObj, err
+		}
+		items = append(items, item)
+	}
+	return consStarFunc(append(items, tail)...)
+}
+
+func quasiquoteWrap(name string, template Expression, depth int, env *Env) (Expression, error) {
+	item, err := quasiquoteTemplate(template, depth, env)
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(Quote(name), item)
 }
 
 func evalLambda(args []Expression, env *Env) (Expression, error) {
 	if len(args) < 2 {
 		return nil, errors.New("not a valid lambda expression")
 	}
-	paramOperand := args[0]
-	body := args[1:]
+	paramNames, err := lambdaParams(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return makeLambdaProcess(paramNames, args[1:], env)
+}
+
+// lambdaParams returns the parameter names of the formals, the rest parameter is preceded by ".".
+func lambdaParams(formals Expression) ([]Symbol, error) {
 	var paramNames []Symbol
-	switch p := paramOperand.(type) {
+	switch p := formals.(type) {
 	case []Expression:
 		for _, e := range p {
 			sym, err := transExpressionToSymbol(e)
@@ -468,9 +673,94 @@ func evalLambda(args []Expression, env *Env) (Expression, error) {
 		if err != nil {
 			return nil, err
 		}
-		paramNames = []Symbol{sym}
+		// (lambda args body) collects all the arguments into args
+		paramNames = []Symbol{".", sym}
+	}
+	return paramNames, nil
+}
+
+// evalLetValues binds the multiple values of the inits to the formals in a new environment, the inits are evaluated
+// in the outer environment.
+// (let-values ((formals init) ...) body ...)
+func evalLetValues(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("let-values: syntax error (let-values should pass the bindings and body)")
+	}
+	bindings, ok := args[0].([]Expression)
+	if !ok {
+		return UndefObj, errors.New("let-values: syntax error (not a valid binding list)")
+	}
+	newEnv := extendEnv(env)
+	for _, b := range bindings {
+		binding, ok := b.([]Expression)
+		if !ok || len(binding) != 2 {
+			return UndefObj, errors.New("let-values: syntax error (not a valid binding)")
+		}
+		if err := bindValues("let-values", binding[0], binding[1], env, newEnv); err != nil {
+			return UndefObj, err
+		}
+	}
+	return bodyInEnv(args[1:], newEnv)
+}
+
+// evalReceive binds the multiple values of the expression to the formals, see SRFI 8.
+// (receive formals expression body ...)
+func evalReceive(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 3 {
+		return UndefObj, errors.New("receive: syntax error (receive should pass the formals, expression and body)")
+	}
+	newEnv := extendEnv(env)
+	if err := bindValues("receive", args[0], args[1], env, newEnv); err != nil {
+		return UndefObj, err
+	}
+	return bodyInEnv(args[2:], newEnv)
+}
+
+// bindValues evaluates the init in env and binds the values to the formals in newEnv.
+func bindValues(name string, formals, init Expression, env, newEnv *Env) error {
+	params, err := lambdaParams(formals)
+	if err != nil {
+		return err
+	}
+	var rest Symbol
+	if n := len(params); n >= 2 && params[n-2] == "." {
+		rest = params[n-1]
+		params = params[:n-2]
+	}
+	ret, err := Eval(init, env)
+	if err != nil {
+		return err
+	}
+	values, ok := ret.(Values)
+	if !ok {
+		values = Values{ret}
+	}
+	if rest == "" && len(values) != len(params) {
+		return fmt.Errorf("%s: expected %d values but got %d", name, len(params), len(values))
+	}
+	if len(values) < len(params) {
+		return fmt.Errorf("%s: expected at least %d values but got %d", name, len(params), len(values))
+	}
+	for i, param := range params {
+		newEnv.Set(param, values[i])
+	}
+	if rest != "" {
+		restValues, err := listImpl(values[len(params):]...)
+		if err != nil {
+			return err
+		}
+		newEnv.Set(rest, restValues)
+	}
+	return nil
+}
+
+// bodyInEnv returns the expression evaluating the body in env in tail position.
+func bodyInEnv(body []Expression, env *Env) (Expression, error) {
+	process, err := makeLambdaProcess(nil, body, env)
+	if err != nil {
+		return UndefObj, err
 	}
-	return makeLambdaProcess(paramNames, body, env), nil
+	return []Expression{process}, nil
 }
 
 func evalDefine(args []Expression, env *Env) (Expression, error) {
@@ -481,6 +771,10 @@ func evalDefine(args []Expression, env *Env) (Expression, error) {
 	s, val := args[0], args[1:]
 	switch se := s.(type) {
 	case []Expression:
+		// (define (name params...) body...), the params may contain the dotted rest parameter
+		if len(se) == 0 {
+			return UndefObj, errors.New("define: bad syntax (missing procedure name)")
+		}
 		var symbols []Symbol
 		for _, e := range se {
 			sym, err := transExpressionToSymbol(e)
@@ -489,7 +783,10 @@ func evalDefine(args []Expression, env *Env) (Expression, error) {
 			}
 			symbols = append(symbols, sym)
 		}
-		p := makeLambdaProcess(symbols[1:], val, env)
+		p, err := makeLambdaProcess(symbols[1:], val, env)
+		if err != nil {
+			return UndefObj, err
+		}
 		env.Set(Symbol(symbols[0]), p)
 	case Expression:
 		if len(val) != 1 {
@@ -516,13 +813,110 @@ func transExpressionToSymbol(s Expression) (Symbol, error) {
 	return "", fmt.Errorf("%v is not a symbol", s)
 }
 
-func makeLambdaProcess(paramNames []Symbol, body []Expression, env *Env) *LambdaProcess {
-	return &LambdaProcess{paramNames, body, env}
+// makeLambdaProcess creates the lambda process, the symbol after the dot of paramNames is the rest parameter which
+// collects the remaining arguments, e.g. (a b . rest).
+func makeLambdaProcess(paramNames []Symbol, body []Expression, env *Env) (*LambdaProcess, error) {
+	var rest Symbol
+	if n := len(paramNames); n >= 2 && paramNames[n-2] == "." {
+		rest = paramNames[n-1]
+		paramNames = paramNames[:n-2]
+	}
+	body, err := scanOutDefines(body)
+	if err != nil {
+		return nil, err
+	}
+	return &LambdaProcess{paramNames, rest, body, env}, nil
+}
+
+// scanOutDefines transforms the leading internal definitions of the body into an equivalent letrec*, so the
+// definitions are visible throughout the body and the local procedures can be mutually recursive.
+// It is an error to define after the expressions of the body.
+func scanOutDefines(body []Expression) ([]Expression, error) {
+	var bindings []Expression
+	i := 0
+	for ; i < len(body); i++ {
+		defines, ok := internalDefines(body[i])
+		if !ok {
+			break
+		}
+		for _, define := range defines {
+			binding, err := defineToBinding(define)
+			if err != nil {
+				return nil, err
+			}
+			bindings = append(bindings, binding)
+		}
+	}
+	for _, exp := range body[i:] {
+		if _, ok := internalDefines(exp); ok {
+			return nil, errors.New("define: syntax error (internal definition after expressions)")
+		}
+	}
+	// the body with only definitions is kept to evaluate as before
+	if len(bindings) == 0 || i == len(body) {
+		return body, nil
+	}
+	letrec := []Expression{"letrec*", bindings}
+	return []Expression{append(letrec, body[i:]...)}, nil
+}
+
+// internalDefines returns the define expressions if the expression is a definition, the begin containing only
+// definitions is spliced.
+func internalDefines(exp Expression) ([][]Expression, bool) {
+	list, ok := exp.([]Expression)
+	if !ok || len(list) == 0 {
+		return nil, false
+	}
+	switch list[0] {
+	case "define":
+		return [][]Expression{list}, true
+	case "begin":
+		var defines [][]Expression
+		for _, e := range list[1:] {
+			d, ok := internalDefines(e)
+			if !ok {
+				return nil, false
+			}
+			defines = append(defines, d...)
+		}
+		return defines, len(defines) > 0
+	}
+	return nil, false
+}
+
+// defineToBinding converts the define expression into the binding of letrec*.
+func defineToBinding(define []Expression) (Expression, error) {
+	if len(define) < 3 {
+		return nil, errors.New("syntax error, require more than two arguments")
+	}
+	switch target := define[1].(type) {
+	case []Expression:
+		if len(target) == 0 {
+			return nil, errors.New("define: bad syntax (missing procedure name)")
+		}
+		lambda := append([]Expression{"lambda", target[1:]}, define[2:]...)
+		return []Expression{target[0], lambda}, nil
+	default:
+		if len(define) != 3 {
+			return nil, errors.New("define: bad syntax (multiple expressions after identifier)")
+		}
+		return []Expression{target, define[2]}, nil
+	}
 }
 
 // EvalAll iterate the sequence of expressions and evaluate each one.
-// Returns the last evaluated value as the result
+// Returns the last evaluated value as the result. The evaluation stops at the first error, a panic raised during
+// evaluation is recovered and returned as error, the effects of the prior expressions are kept.
 func EvalAll(exps []Expression, env *Env) (ret Expression, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			// the escape of continuation should unwind to the call/cc
+			if _, ok := r.(*continuationInvoked); ok {
+				panic(r)
+			}
+			ret, err = UndefObj, newSchemeError(r)
+		}
+	}()
 	for _, exp := range exps {
 		ret, err = Eval(exp, env)
 		if err != nil {
@@ -532,19 +926,18 @@ func EvalAll(exps []Expression, env *Env) (ret Expression, err error) {
 	return
 }
 
-func expressionToNumber(exp Expression) (Number, error) {
+// expressionToNumber returns the numeric value of expression, which is either Integer or Number.
+func expressionToNumber(exp Expression) (Expression, error) {
 	v := exp
 	if !IsNumber(v) {
-		return 0, fmt.Errorf("%v is not a number", v)
+		return UndefObj, fmt.Errorf("%v is not a number", v)
 	}
 	switch t := v.(type) {
-	case Number:
-		return t, nil
 	case string:
-		f, _ := strconv.ParseFloat(t, 64)
-		return Number(f), nil
+		return parseNumber(t)
+	default:
+		return t, nil
 	}
-	return 0, nil
 }
 
 func conditionOfIfExpression(exp []Expression) (Expression, error) {
@@ -589,22 +982,145 @@ func evalIf(args []Expression, env *Env) (Expression, error) {
 	return elseExpOfIfExpression(args)
 }
 
+// evalBegin evaluates the expressions in order and returns the last one to evaluate in tail position.
+// (begin) is unspecified.
 func evalBegin(args []Expression, env *Env) (Expression, error) {
-	if len(args) < 1 {
-		return UndefObj, errors.New("syntax error (requires more than 1 arguments)")
+	if len(args) == 0 {
+		return UndefObj, nil
 	}
 	for _, e := range args[:len(args)-1] {
-		Eval(e, env)
+		if _, err := Eval(e, env); err != nil {
+			return UndefObj, err
+		}
 	}
 	return args[len(args)-1], nil
 }
 
+// evalCond expands the cond expression into nested if expressions, which are evaluated by the caller so the
+// clause bodies are in tail position.
 func evalCond(exp []Expression, env *Env) (Expression, error) {
-	equalIfExp, err := expandCond(exp)
+	return expandCond(exp)
+}
+
+// evalDo runs the do loop. All the step expressions are evaluated before the variables are rebound, and the
+// variables without step expression keep their values.
+func evalDo(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("do: syntax error (do should pass the variables and test clause)")
+	}
+	specs, ok := args[0].([]Expression)
+	if !ok {
+		return UndefObj, errors.New("do: syntax error (not a valid variable spec)")
+	}
+	testClause, ok := args[1].([]Expression)
+	if !ok || len(testClause) < 1 {
+		return UndefObj, errors.New("do: syntax error (not a valid test clause)")
+	}
+	commands := args[2:]
+	var params []Symbol
+	steps := make(map[Symbol]Expression)
+	loopEnv := extendEnv(env)
+	for _, exp := range specs {
+		spec, ok := exp.([]Expression)
+		if !ok || len(spec) < 2 || len(spec) > 3 {
+			return UndefObj, errors.New("do: syntax error (not a valid variable spec)")
+		}
+		sym, err := transExpressionToSymbol(spec[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		val, err := Eval(spec[1], env)
+		if err != nil {
+			return UndefObj, err
+		}
+		loopEnv.Set(sym, val)
+		params = append(params, sym)
+		if len(spec) == 3 {
+			steps[sym] = spec[2]
+		}
+	}
+	for {
+		test, err := Eval(testClause[0], loopEnv)
+		if err != nil {
+			return UndefObj, err
+		}
+		if IsTrue(test) {
+			break
+		}
+		if _, err := EvalAll(commands, loopEnv); err != nil {
+			return UndefObj, err
+		}
+		nextEnv := extendEnv(env)
+		for _, sym := range params {
+			val, _ := loopEnv.lookup(sym)
+			if step, ok := steps[sym]; ok {
+				val, err = Eval(step, loopEnv)
+				if err != nil {
+					return UndefObj, err
+				}
+			}
+			nextEnv.Set(sym, val)
+		}
+		loopEnv = nextEnv
+	}
+	if len(testClause) == 1 {
+		return UndefObj, nil
+	}
+	process, err := makeLambdaProcess(nil, testClause[1:], loopEnv)
+	if err != nil {
+		return UndefObj, err
+	}
+	return []Expression{process}, nil
+}
+
+// evalWhen expands the when expression into an if expression with the body as the consequent.
+func evalWhen(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("when: syntax error (when should pass the test and body)")
+	}
+	return makeIf(args[0], sequenceToExp(args[1:]), UndefObj), nil
+}
+
+// evalUnless expands the unless expression into an if expression with the body as the alternative.
+func evalUnless(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("unless: syntax error (unless should pass the test and body)")
+	}
+	return makeIf(args[0], UndefObj, sequenceToExp(args[1:])), nil
+}
+
+// evalCase evaluates the key once and returns the body of the first clause whose datums contain the key.
+func evalCase(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 1 {
+		return UndefObj, errors.New("case: syntax error (case should pass the key and clauses)")
+	}
+	key, err := Eval(args[0], env)
 	if err != nil {
 		return UndefObj, err
 	}
-	return Eval(equalIfExp, env)
+	for _, exp := range args[1:] {
+		clause, ok := exp.([]Expression)
+		if !ok || len(clause) < 2 {
+			return UndefObj, errors.New("case: syntax error (not a valid clause)")
+		}
+		if isElseClause(clause) {
+			return sequenceToExp(clause[1:]), nil
+		}
+		data, ok := clause[0].([]Expression)
+		if !ok {
+			return UndefObj, errors.New("case: syntax error (datums of clause should be a list)")
+		}
+		for _, d := range data {
+			datum, err := evalQuote([]Expression{d}, env)
+			if err != nil {
+				return UndefObj, err
+			}
+			if isEqv(key, datum) {
+				return sequenceToExp(clause[1:]), nil
+			}
+		}
+	}
+	return UndefObj, nil
 }
 
 func makeIf(condition, trueExp, elseExp Expression) []Expression {
@@ -640,7 +1156,7 @@ func processesOfClause(exp []Expression) (Expression, error) {
 func isElseClause(clause Expression) bool {
 	switch v := clause.(type) {
 	case []Expression:
-		return v[0] == "else"
+		return len(v) > 0 && v[0] == "else"
 	default:
 		return false
 	}
@@ -671,6 +1187,25 @@ func condClausesToIf(exp []Expression) (Expression, error) {
 	if err != nil {
 		return UndefObj, err
 	}
+	if len(first) == 1 {
+		// the clause without body returns the value of the test, which is evaluated only once
+		elseIfClause, err := condClausesToIf(rest)
+		if err != nil {
+			return UndefObj, err
+		}
+		return []Expression{"or", condition, elseIfClause}, nil
+	}
+	if len(first) == 3 && first[1] == "=>" {
+		// (test => receiver) passes the value of test to the receiver, the value is bound to a fresh symbol so the
+		// test is evaluated only once
+		elseIfClause, err := condClausesToIf(rest)
+		if err != nil {
+			return UndefObj, err
+		}
+		value := gensym("cond-value")
+		bindings := []Expression{[]Expression{value, condition}}
+		return []Expression{"let", bindings, makeIf(value, []Expression{first[2], value}, elseIfClause)}, nil
+	}
 	clause, err = processesOfClause(first)
 	if err != nil {
 		return UndefObj, err
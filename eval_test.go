@@ -2,6 +2,8 @@ package goscheme
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
 	"testing"
 )
 
@@ -9,29 +11,29 @@ func TestEval(t *testing.T) {
 	builtinEnv := setupBuiltinEnv()
 	var ret Expression
 	ret, _ = Eval("3", builtinEnv)
-	assert.Equal(t, ret, Number(3))
+	assert.Equal(t, ret, Integer(3))
 	Eval([]Expression{"define", "x", "3"}, builtinEnv)
 	ret, _ = Eval("x", builtinEnv)
-	assert.Equal(t, Number(3), ret)
+	assert.Equal(t, Integer(3), ret)
 	Eval([]Expression{"define", []Expression{"fn", "y"}, []Expression{"+", "x", "y"}}, builtinEnv)
 	ret, _ = Eval([]Expression{"fn", "x"}, builtinEnv)
-	assert.Equal(t, Number(6), ret)
+	assert.Equal(t, Integer(6), ret)
 
 	// test begin
 	ret, _ = Eval([]Expression{"begin", "1"}, builtinEnv)
-	assert.Equal(t, Number(1), ret)
+	assert.Equal(t, Integer(1), ret)
 	ret, _ = Eval([]Expression{"begin", "#t"}, builtinEnv)
 	assert.Equal(t, true, ret)
 	ret, _ = Eval([]Expression{"begin", "1", []Expression{"+", "1", "2", "3"}}, builtinEnv)
-	assert.Equal(t, Number(6), ret)
+	assert.Equal(t, Integer(6), ret)
 
 	// test if
 	testCases := []struct {
 		input    Expression
 		expected Expression
 	}{
-		{[]Expression{"if", "#t", "1", "0"}, Number(1)},
-		{[]Expression{"if", "#f", "1", "0"}, Number(0)},
+		{[]Expression{"if", "#t", "1", "0"}, Integer(1)},
+		{[]Expression{"if", "#f", "1", "0"}, Integer(0)},
 		{[]Expression{"if", "#f", "1"}, UndefObj},
 	}
 	for _, c := range testCases {
@@ -44,9 +46,9 @@ func TestEval(t *testing.T) {
 		input    Expression
 		expected Expression
 	}{
-		{[]Expression{"cond", []Expression{"#t", "1", "2"}}, Number(2)},
+		{[]Expression{"cond", []Expression{"#t", "1", "2"}}, Integer(2)},
 		{[]Expression{"cond", []Expression{"#f", "1", "2"}}, UndefObj},
-		{[]Expression{"cond", []Expression{"#f", "1", "2"}, []Expression{"#t", "2"}}, Number(2)},
+		{[]Expression{"cond", []Expression{"#f", "1", "2"}, []Expression{"#t", "2"}}, Integer(2)},
 		{[]Expression{"cond", []Expression{"#f", "1", "2"}, []Expression{"else", `"else clause"`}}, String(`else clause`)},
 	}
 	for _, c := range testCases {
@@ -70,7 +72,7 @@ func TestEval(t *testing.T) {
 
 	// test lambda
 	ret, _ = EvalAll(strToToken("((lambda (x y) (+ x y)) 1 2)"), builtinEnv)
-	assert.Equal(t, Number(3), ret)
+	assert.Equal(t, Integer(3), ret)
 
 	// test recursion
 	tz := NewTokenizerFromString(
@@ -90,13 +92,13 @@ func TestEval(t *testing.T) {
 		input    Expression
 		expected Expression
 	}{
-		{[]Expression{"fact1", "2"}, Number(2)},
-		{[]Expression{"fact1", "6"}, Number(720)},
-		{[]Expression{"fact1", "0"}, Number(1)},
+		{[]Expression{"fact1", "2"}, Integer(2)},
+		{[]Expression{"fact1", "6"}, Integer(720)},
+		{[]Expression{"fact1", "0"}, Integer(1)},
 		// tail recursion
-		{[]Expression{"fact2", "2"}, Number(2)},
-		{[]Expression{"fact2", "6"}, Number(720)},
-		{[]Expression{"fact2", "0"}, Number(1)},
+		{[]Expression{"fact2", "2"}, Integer(2)},
+		{[]Expression{"fact2", "6"}, Integer(720)},
+		{[]Expression{"fact2", "0"}, Integer(1)},
 	}
 	for _, c := range testCases {
 		ret, _ = Eval(c.input, builtinEnv)
@@ -108,64 +110,78 @@ func TestEval(t *testing.T) {
 		input    Expression
 		expected Expression
 	}{
-		{[]Expression{"cons", "1", "2"}, &Pair{Number(1), Number(2)}},
+		{[]Expression{"cons", "1", "2"}, &Pair{Integer(1), Integer(2)}},
 	}
 	for _, c := range testCases {
 		ret, _ = Eval(c.input, builtinEnv)
 		assert.Equal(t, c.expected, ret)
 	}
 	ret, _ = Eval([]Expression{"cons", "1", "2"}, builtinEnv)
-	assert.Equal(t, &Pair{Number(1), Number(2)}, ret)
+	assert.Equal(t, &Pair{Integer(1), Integer(2)}, ret)
 
 	//// test list
 	ret, _ = Eval([]Expression{"list", "1", "2"}, builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), NilObj}}, ret)
 	ret, _ = Eval([]Expression{"list", "1"}, builtinEnv)
-	assert.Equal(t, &Pair{Number(1), NilObj}, ret)
+	assert.Equal(t, &Pair{Integer(1), NilObj}, ret)
 	ret, _ = Eval([]Expression{"list"}, builtinEnv)
 	assert.Equal(t, NilObj, ret)
 	ret, _ = Eval([]Expression{"list", "1", []Expression{"cons", "1", []Expression{}}}, builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{&Pair{Number(1), NilObj}, NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{&Pair{Integer(1), NilObj}, NilObj}}, ret)
 
 	//// test append
 	ret, _ = EvalAll(strToToken("(append (cons 1 ()) 2)"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), Integer(2)}, ret)
 	ret, _ = EvalAll(strToToken("(append () 2)"), builtinEnv)
-	assert.Equal(t, &Pair{Number(2), NilObj}, ret)
+	assert.Equal(t, Integer(2), ret)
 	ret, _ = EvalAll(strToToken("(append (cons 1 ()) (cons 2 ()))"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), NilObj}}, ret)
 	ret, _ = EvalAll(strToToken("(append (cons 1 ()) ())"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), NilObj}, ret)
-	ret, _ = EvalAll(strToToken("(append (cons 1 ()) 2 3)"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), &Pair{Number(3), NilObj}}}, ret)
+	assert.Equal(t, &Pair{Integer(1), NilObj}, ret)
+	_, err := EvalAll(strToToken("(append (cons 1 ()) 2 3)"), builtinEnv)
+	assert.NotNil(t, err)
 	ret, _ = EvalAll(strToToken("(append (cons 1 ()) (cons 2 ()) (cons 3 ()))"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), &Pair{Number(3), NilObj}}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), NilObj}}}, ret)
 
 	// test quote
 	ret, _ = EvalAll(strToToken("(quote (1 2))"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), NilObj}}, ret)
 	ret, _ = EvalAll(strToToken("(quote 1)"), builtinEnv)
-	assert.Equal(t, Number(1), ret)
+	assert.Equal(t, Integer(1), ret)
 	ret, _ = EvalAll(strToToken(`(quote "x")`), builtinEnv)
 	assert.Equal(t, String("x"), ret)
 	ret, _ = EvalAll(strToToken(`(quote (1 "x"))`), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{String("x"), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{String("x"), NilObj}}, ret)
 	ret, _ = EvalAll(strToToken(`(quote (cons 1 "x"))`), builtinEnv)
-	assert.Equal(t, &Pair{Quote("cons"), &Pair{Number(1), &Pair{String("x"), NilObj}}}, ret)
+	assert.Equal(t, &Pair{Quote("cons"), &Pair{Integer(1), &Pair{String("x"), NilObj}}}, ret)
 	ret, _ = EvalAll(strToToken(`(quote (1 (2 3) 4))`), builtinEnv)
 	assert.Equal(t, &Pair{
-		Number(1),
+		Integer(1),
 		&Pair{
-			&Pair{Number(2), &Pair{Number(3), NilObj}}, &Pair{Number(4), NilObj}}},
+			&Pair{Integer(2), &Pair{Integer(3), NilObj}}, &Pair{Integer(4), NilObj}}},
 		ret)
 	ret, _ = EvalAll(strToToken("'(1 2)"), builtinEnv)
-	assert.Equal(t, &Pair{Number(1), &Pair{Number(2), NilObj}}, ret)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), NilObj}}, ret)
 	ret, _ = EvalAll(strToToken("'x"), builtinEnv)
 	assert.Equal(t, Quote("x"), ret)
 	ret, _ = EvalAll(strToToken("'(cons define 3)"), builtinEnv)
-	assert.Equal(t, &Pair{Quote("cons"), &Pair{Quote("define"), &Pair{Number(3), NilObj}}}, ret)
+	assert.Equal(t, &Pair{Quote("cons"), &Pair{Quote("define"), &Pair{Integer(3), NilObj}}}, ret)
 	ret, _ = EvalAll(strToToken("''(cons define 3)"), builtinEnv)
-	assert.Equal(t, &Pair{Quote("quote"), &Pair{&Pair{Quote("cons"), &Pair{Quote("define"), &Pair{Number(3), NilObj}}}, NilObj}}, ret)
+	assert.Equal(t, &Pair{Quote("quote"), &Pair{&Pair{Quote("cons"), &Pair{Quote("define"), &Pair{Integer(3), NilObj}}}, NilObj}}, ret)
+	ret, _ = EvalAll(strToToken("(quote ())"), builtinEnv)
+	assert.Equal(t, NilObj, ret)
+	ret, _ = EvalAll(strToToken("'(1 . 2)"), builtinEnv)
+	assert.Equal(t, &Pair{Integer(1), Integer(2)}, ret)
+	ret, _ = EvalAll(strToToken("'(1 2 . x)"), builtinEnv)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), Quote("x")}}, ret)
+	ret, _ = EvalAll(strToToken("'(1 . (2 3))"), builtinEnv)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), NilObj}}}, ret)
+	ret, _ = EvalAll(strToToken("'((a . b) . ())"), builtinEnv)
+	assert.Equal(t, &Pair{&Pair{Quote("a"), Quote("b")}, NilObj}, ret)
+	ret, _ = EvalAll(strToToken("(define x 2) `(1 . ,x)"), builtinEnv)
+	assert.Equal(t, &Pair{Integer(1), Integer(2)}, ret)
+	ret, _ = EvalAll(strToToken("(define y '(3 4)) `(1 ,@y . 5)"), builtinEnv)
+	assert.Equal(t, &Pair{Integer(1), &Pair{Integer(3), &Pair{Integer(4), Integer(5)}}}, ret)
 }
 
 // test built in procedures
@@ -182,22 +198,22 @@ func TestEval3(t *testing.T) {
 	}{
 		{`
 				;comment
-				3`, Number(3)},
+				3`, Integer(3)},
 		{`
 				;comment
 				3
-				;comment`, Number(3)},
+				;comment`, Integer(3)},
 		{`
 				; comment
 				(define x 3)
 				; comment 2
-				x`, Number(3)},
+				x`, Integer(3)},
 		{`
 				;comment
 				(define (func ; comment
 						 x)
 					x)
-				(func 3)`, Number(3)},
+				(func 3)`, Integer(3)},
 		{`
 				;comment
 				(define (func ; comment
@@ -218,26 +234,30 @@ func TestEval4(t *testing.T) {
 		input    string
 		expected Expression
 	}{
-		{`(eval 3)`, Number(3)},
-		{`(eval '3)`, Number(3)},
-		{`(eval '(begin (display "") 3))`, Number(3)},
+		{`(eval 3)`, Integer(3)},
+		{`(eval '3)`, Integer(3)},
+		{`(eval '(begin (display "") 3))`, Integer(3)},
 		{`
 			(define fn '*)
 (define x 3)
 (define y (list '+ x 5))
 (define z (list fn 10 y))
-(eval y)`, Number(8)},
+(eval y)`, Integer(8)},
 		{`
 			(define fn '*)
 (define x 3)
 (define y (list '+ x 5))
 (define z (list fn 10 y))
-(eval z)`, Number(80)},
-		{`(define x 3) (eval 'x)`, Number(3)},
+(eval z)`, Integer(80)},
+		{`(define x 3) (eval 'x)`, Integer(3)},
 		{`(define x 3) (eval ''x)`, Quote("x")},
 		{`(apply display '(3))`, UndefObj},
-		{`(apply (lambda x x) '(3))`, Number(3)},
-		{`(apply (lambda (x y) (+ x y)) '(3 4))`, Number(7)},
+		{`(apply (lambda x x) '(3))`, &Pair{Integer(3), NilObj}},
+		{`(apply (lambda (x y) (+ x y)) '(3 4))`, Integer(7)},
+		{`(apply + 1 2 '(3 4))`, Integer(10)},
+		{`(apply list 'a '())`, &Pair{Quote("a"), NilObj}},
+		{`(apply + 1 '())`, Integer(1)},
+		{`(define (loop n) (if (= n 0) 'done (apply loop (list (- n 1))))) (loop 100000)`, Quote("done")},
 	}
 	for _, c := range testCases {
 		env := setupBuiltinEnv()
@@ -263,8 +283,10 @@ func TestEval5(t *testing.T) {
 		expected Expression
 	}{
 		{`(thunk? (delay (+ 1 2)))`, true},
-		{`(force (delay (+ 1 2)))`, Number(3)},
-		{`(define (try a b) (if (= a 0) b a)) (try 1 (delay (+ 1 "x")))`, Number(1)},
+		{`(force (delay (+ 1 2)))`, Integer(3)},
+		{`(define (try a b) (if (= a 0) b a)) (try 1 (delay (+ 1 "x")))`, Integer(1)},
+		{`(force 3)`, Integer(3)},
+		{`(define n 0) (define p (delay (begin (set! n (+ n 1)) n))) (force p) (force p) n`, Integer(1)},
 		// eval error
 		{`(define (try a b) (if (= a 0) (force b) (force a))) (try 0 (delay (+ 1 "x")))`, UndefObj},
 	}
@@ -281,7 +303,7 @@ func TestEval6(t *testing.T) {
 		input    string
 		expected Expression
 	}{
-		{`(or 1 unbound-syntax)`, true},
+		{`(or 1 unbound-syntax)`, Integer(1)},
 		// eval error
 		{`(and 1 unbound-syntax)`, UndefObj},
 	}
@@ -299,15 +321,15 @@ func TestEval7(t *testing.T) {
 		expected Expression
 	}{
 		{`(let ((x 2) (y 3))
-  					(* x y))`, Number(6)},
+  					(* x y))`, Integer(6)},
 		{`(let ((x 2) (y 3))
   					(let ((foo (lambda (z) (+ x y z)))
         				(x 7))
-    					(foo 4))) `, Number(9)},
+    					(foo 4))) `, Integer(9)},
 		{`(let ((x 2) (y 3))
   					(let* ((x 7)
          				(z (+ x y)))
-						(* z x)))`, Number(70)},
+						(* z x)))`, Integer(70)},
 		{`(letrec (
 					(zero? (lambda (x) (= x 0)))
 					(even?
@@ -321,11 +343,122 @@ func TestEval7(t *testing.T) {
                				#f
                				(even? (- n 1))))))
 				(even? 88))`, true},
+		{`(let () 5)`, Integer(5)},
+		{`(define x 1) (let ((x 2) (y x)) y)`, Integer(1)},
+		{`(let ((x 1)) (define y 2) (+ x y))`, Integer(3)},
+		{`(let* ((x 1) (y (+ x 1))) y)`, Integer(2)},
+		{`(let* () 5)`, Integer(5)},
+		{`(let* ((x 1) (x (+ x 1))) x)`, Integer(2)},
+		{`(letrec ((even? (lambda (n) (if (= n 0) #t (odd? (- n 1)))))
+					(odd? (lambda (n) (if (= n 0) #f (even? (- n 1))))))
+				(even? 10))`, true},
 		{`(letrec ((b a) (a 1)) b)`, UndefObj},
+		{`(let loop ((i 0) (acc 0)) (if (= i 10) acc (loop (+ i 1) (+ acc i))))`, Integer(45)},
+		{`(let loop ((i 0)) (if (= i 1000000) i (loop (+ i 1))))`, Integer(1000000)},
+		{`(define n 0) (define (inc) (set! n (+ n 1))) (let ((n 10)) (inc)) (inc) n`, Integer(2)},
+		{`(define (make-counter) (let ((count 0)) (lambda () (set! count (+ count 1)) count)))
+				(define c (make-counter)) (c) (c)`, Integer(2)},
 		{`(define (f a)
 					(let ((b 3)) (set! a 3))
 					a)
-				(f 4)`, Number(3)},
+				(f 4)`, Integer(3)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+	_, err := EvalAll(strToToken(`(letrec ((b a) (a 1)) b)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+	_, err = EvalAll(strToToken(`(let ((x 1)) (let ((y 2)) (set! z 3)))`), setupBuiltinEnv())
+	assert.EqualError(t, err, "set!: cannot set undefined variable: z")
+}
+
+// test case
+func TestEval8(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(case (* 2 3) ((2 3 5 7) 'prime) ((1 4 6 8 9) 'composite) (else 'unknown))`, Quote("composite")},
+		{`(case 10 ((2 3 5 7) 'prime) (else 'unknown))`, Quote("unknown")},
+		{`(case 'b ((a) 1) ((b c) 2))`, Integer(2)},
+		{`(case 'd ((a) 1) ((b c) 2))`, UndefObj},
+		{`(define n 0) (case (begin (set! n (+ n 1)) n) ((1) (set! n 10) n) (else n))`, Integer(10)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+}
+
+// test when, unless
+func TestEval9(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(when (> 1 0) 1 2)`, Integer(2)},
+		{`(when (< 1 0) 1 2)`, UndefObj},
+		{`(unless (< 1 0) 1 2)`, Integer(2)},
+		{`(unless (> 1 0) 1 2)`, UndefObj},
+		{`(define x '()) (when #t (set! x (cons 1 x)) (set! x (cons 2 x))) x`, &Pair{Integer(2), &Pair{Integer(1), NilObj}}},
+		{`(define (count n) (unless (= n 0) (count (- n 1)))) (count 100000)`, UndefObj},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+}
+
+// test do
+func TestEval10(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(do ((i 0 (+ i 1)) (sum 0 (+ sum i))) ((= i 5) sum))`, Integer(10)},
+		{`(do ((i 0 (+ i 1))) ((= i 5)))`, UndefObj},
+		{`(do ((i 0 (+ i 1)) (j 10)) ((= i 3) j))`, Integer(10)},
+		{`(do ((x 1 y) (y 2 x) (i 0 (+ i 1))) ((= i 1) (list x y)))`, &Pair{Integer(2), &Pair{Integer(1), NilObj}}},
+		{`(define acc '()) (do ((i 0 (+ i 1))) ((= i 3) acc) (set! acc (cons i acc)))`,
+			&Pair{Integer(2), &Pair{Integer(1), &Pair{Integer(0), NilObj}}}},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+}
+
+// test quasiquote
+func TestEval11(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(quasiquote (1 2 (unquote (+ 1 2)) (unquote-splicing (list 4 5))))`,
+			&Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), &Pair{Integer(4), &Pair{Integer(5), NilObj}}}}}},
+		{"`(1 2 ,(+ 1 2) ,@(list 4 5))",
+			&Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), &Pair{Integer(4), &Pair{Integer(5), NilObj}}}}}},
+		{`(quasiquote x)`, Quote("x")},
+		{`(define x 3) (quasiquote (unquote x))`, Integer(3)},
+		{`(quasiquote (1 (unquote-splicing '())))`, &Pair{Integer(1), NilObj}},
+		{`(quasiquote (a (quasiquote (b (unquote (c (unquote (+ 1 2))))))))`,
+			&Pair{Quote("a"), &Pair{
+				&Pair{Quote("quasiquote"), &Pair{
+					&Pair{Quote("b"), &Pair{
+						&Pair{Quote("unquote"), &Pair{
+							&Pair{Quote("c"), &Pair{Integer(3), NilObj}}, NilObj}}, NilObj}}, NilObj}}, NilObj}}},
+		// the nested unquote-splicing decreases the depth like unquote
+		{"`(a `(b ,@(c ,(+ 1 2))))",
+			&Pair{Quote("a"), &Pair{
+				&Pair{Quote("quasiquote"), &Pair{
+					&Pair{Quote("b"), &Pair{
+						&Pair{Quote("unquote-splicing"), &Pair{
+							&Pair{Quote("c"), &Pair{Integer(3), NilObj}}, NilObj}}, NilObj}}, NilObj}}, NilObj}}},
 	}
 	for _, c := range testCases {
 		env := setupBuiltinEnv()
@@ -334,6 +467,420 @@ func TestEval7(t *testing.T) {
 	}
 }
 
+// test variadic lambda
+func TestEval12(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`((lambda (a b . rest) rest) 1 2 3 4)`, &Pair{Integer(3), &Pair{Integer(4), NilObj}}},
+		{`((lambda (a b . rest) rest) 1 2)`, NilObj},
+		{`((lambda (a b . rest) (+ a b)) 1 2 3)`, Integer(3)},
+		{`((lambda args args) 1 2)`, &Pair{Integer(1), &Pair{Integer(2), NilObj}}},
+		{`((lambda args args))`, NilObj},
+		{`((lambda (a . rest) a))`, UndefObj},
+		{`(define (sum first . more) (if (null? more) first (+ first (apply sum more)))) (sum 1 2 3 4)`, Integer(10)},
+		{`(define (f first . more) more) (f 1 2 3 4)`, &Pair{Integer(2), &Pair{Integer(3), &Pair{Integer(4), NilObj}}}},
+		{`(define (f . args) args) (f)`, NilObj},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+}
+
+// test call/cc
+func TestEval13(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(+ 1 (call/cc (lambda (k) (+ 10 (k 2)))))`, Integer(3)},
+		{`(call/cc (lambda (k) 5))`, Integer(5)},
+		{`(define (find-first pred lst)
+					(call-with-current-continuation
+						(lambda (return)
+							(map (lambda (x) (if (pred x) (return x))) lst)
+							#f)))
+				(find-first (lambda (x) (> x 2)) '(1 2 3 4))`, Integer(3)},
+		{`(call/cc (lambda (outer) (+ 1 (call/cc (lambda (inner) (outer 10))))))`, Integer(10)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+	// re-entering the continuation is not supported
+	_, err := EvalAll(strToToken(`(define saved '()) (call/cc (lambda (k) (set! saved k))) (saved 1)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+// test multiple values
+func TestEval14(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(call-with-values (lambda () (values 1 2)) +)`, Integer(3)},
+		{`(call-with-values (lambda () (values)) list)`, NilObj},
+		{`(call-with-values (lambda () 5) (lambda (x) (* x 2)))`, Integer(10)},
+		{`(+ (values 1) 2)`, Integer(3)},
+		{`(values 1 2)`, Values{Integer(1), Integer(2)}},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, _ := EvalAll(strToToken(c.input), env)
+		assert.Equal(t, c.expected, ret)
+	}
+	assert.Equal(t, "1 \"a\"", Values{Integer(1), String("a")}.String())
+}
+
+// test errors are returned from EvalAll
+func TestEval15(t *testing.T) {
+	env := setupBuiltinEnv()
+	env.Set("boom", NewFunction("boom", func(args ...Expression) (Expression, error) {
+		panic("boom")
+	}, 0, 0))
+	ret, err := EvalAll(strToToken(`(define x 1) (boom) (define x 2)`), env)
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, UndefObj, ret)
+	x, _ := env.Find("x")
+	assert.Equal(t, Integer(1), x)
+
+	_, err = EvalAll(strToToken(`(define y 1) (undefined-procedure) (define y 2)`), env)
+	assert.NotNil(t, err)
+	y, _ := env.Find("y")
+	assert.Equal(t, Integer(1), y)
+
+	// the escape of continuation still works through the nested evaluation
+	ret, err = EvalAll(strToToken(`(call/cc (lambda (k) (eval '(k 42))))`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(42), ret)
+}
+
+// test the body of lambda is evaluated as an implicit begin
+func TestEval16(t *testing.T) {
+	env := setupBuiltinEnv()
+	var ret Expression
+	var err error
+	output := captureStdout(func() {
+		ret, err = EvalAll(strToToken(`((lambda (x) (display "x is ") (display x) (* x 2)) 21)`), env)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(42), ret)
+	assert.Equal(t, "x is 21", output)
+
+	// the error of the leading expression stops the evaluation of the body
+	_, err = EvalAll(strToToken(`(define y 1) ((lambda () (car '()) (set! y 2)))`), env)
+	assert.NotNil(t, err)
+	y, _ := env.Find("y")
+	assert.Equal(t, Integer(1), y)
+
+	// the last expression is in tail position
+	ret, err = EvalAll(strToToken(`
+		(define (loop n acc)
+		  (set! y n)
+		  (if (= n 0) acc (loop (- n 1) (+ acc 1))))
+		(loop 100000 0)`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, Integer(100000), ret)
+}
+
+// test internal definitions at the start of body
+func TestEval17(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`((lambda (x) (define y (* x 2)) (+ x y)) 1)`, Integer(3)},
+		{`(define (f x) (define (g) (* x y)) (define y 3) (g)) (f 2)`, Integer(6)},
+		{`(let ((n 10))
+		    (define (even? n) (if (= n 0) #t (odd? (- n 1))))
+		    (define (odd? n) (if (= n 0) #f (even? (- n 1))))
+		    (even? n))`, true},
+		{`(let loop ((i 0)) (define next (+ i 1)) (if (= next 5) i (loop next)))`, Integer(4)},
+		{`(letrec* ((a 1) (b (+ a 1))) (define c (+ b 1)) (list a b c))`,
+			&Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), NilObj}}}},
+		{`((lambda () (begin (define a 1) (define b 2)) (define c 3) (+ a b c)))`, Integer(6)},
+		{`(define (h . args) (define n (length args)) n) (h 1 2 3)`, Integer(3)},
+		// the internal definitions do not leak into the outer environment
+		{`(define z 1) ((lambda () (define z 2) z)) z`, Integer(1)},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	errorCases := []string{
+		`(lambda (x) (display x) (define y 1) y)`,
+		`(let () 1 (define y 1) y)`,
+		`(lambda () (define) 1)`,
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c), setupBuiltinEnv())
+		assert.NotNil(t, err, c)
+	}
+}
+
+// test the deep tail recursion through cond, case, when and unless
+func TestEval18(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(define (loop n) (cond ((= n 0) 'done) (else (loop (- n 1))))) (loop 100000)`, Quote("done")},
+		{`(define (loop n) (cond ((> n 0) (loop (- n 1))) (else 'done))) (loop 100000)`, Quote("done")},
+		{`(define (loop n) (case n ((0) 'done) (else (loop (- n 1))))) (loop 100000)`, Quote("done")},
+		{`(define (loop n) (when (> n 0) (loop (- n 1)))) (loop 100000)`, UndefObj},
+		{`(define (loop n) (unless (= n 0) (loop (- n 1)))) (loop 100000)`, UndefObj},
+		{`(cond (#f 1))`, UndefObj},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+// test the values and tail position of and/or
+func TestEval19(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(and)`, true},
+		{`(or)`, false},
+		{`(and 1 2)`, Integer(2)},
+		{`(and 1 #f 2)`, false},
+		{`(or #f '(1))`, &Pair{Integer(1), NilObj}},
+		{`(or #f "a" 2)`, String("a")},
+		{`(or #f #f)`, false},
+		{`(define (loop n) (and #t (if (= n 0) 'done (loop (- n 1))))) (loop 100000)`, Quote("done")},
+		{`(define (loop n) (or (= n 0) (loop (- n 1)))) (loop 100000)`, true},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+// test begin with any number of expressions
+func TestEval20(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(begin)`, UndefObj},
+		{`(begin 1)`, Integer(1)},
+		{`(begin (+ 1 2))`, Integer(3)},
+		{`(define x 1) (begin (set! x (+ x 1)) (set! x (* x 10)) x)`, Integer(20)},
+		{`(define (f) (begin)) (f)`, UndefObj},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+// test cond without matching clause
+func TestEval21(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(cond)`, UndefObj},
+		{`(cond (#f 1))`, UndefObj},
+		{`(cond (#f 1) ((= 1 2) 2))`, UndefObj},
+		{`(cond (#f))`, UndefObj},
+		{`(cond (#f) (2))`, Integer(2)},
+		{`(define x 0) (cond ((begin (set! x (+ x 1)) x)) (else 'no)) x`, Integer(1)},
+		{`(cond ((assv 2 '((1 a) (2 b)))) (else 'no))`, &Pair{Integer(2), &Pair{Quote("b"), NilObj}}},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(cond ())`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+// test the => clause of cond
+func TestEval22(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(cond ((assv 2 '((1 a) (2 b))) => (lambda (p) (car (cdr p)))) (else 'none))`, Quote("b")},
+		{`(cond ((assv 3 '((1 a) (2 b))) => (lambda (p) (car (cdr p)))) (else 'none))`, Quote("none")},
+		{`(cond ((assv 3 '((1 a) (2 b))) => (lambda (p) (car (cdr p)))))`, UndefObj},
+		{`(define n 0) (cond ((begin (set! n (+ n 1)) n) => (lambda (x) (* x 10)))) `, Integer(10)},
+		{`(define n 0) (cond ((begin (set! n (+ n 1)) n) => (lambda (x) x))) n`, Integer(1)},
+		// the receiver sees the symbols of the enclosing environment
+		{`(define value 5) (cond (#t => (lambda (x) value)))`, Integer(5)},
+		{`(define (loop n) (cond ((= n 0) => (lambda (x) 'done)) (else (loop (- n 1))))) (loop 100000)`,
+			Quote("done")},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestEval23(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(let-values (((q r) (floor/ 7 2))) (list q r))`, "(3 1)"},
+		{`(let-values (((q r) (floor/ -7 2))) (list q r))`, "(-4 1)"},
+		{`(let-values (((q r) (truncate/ -7 2))) (list q r))`, "(-3 -1)"},
+		{`(let-values (((a b) (values 1 2)) ((c) (values 3))) (list a b c))`, "(1 2 3)"},
+		{`(let-values (((a . rest) (values 1 2 3)) (all (values 4 5))) (list a rest all))`, "(1 (2 3) (4 5))"},
+		{`(let-values ((() (values))) 'empty)`, "empty"},
+		// the inits are evaluated in the outer environment
+		{`(define a 10) (let-values (((a) (values 1)) ((b) (values a))) (list a b))`, "(1 10)"},
+		{`(receive (q r) (floor/ 7 2) (list q r))`, "(3 1)"},
+		{`(receive (a . rest) (values 1 2 3) (list a rest))`, "(1 (2 3))"},
+		{`(receive all (values 1 2) all)`, "(1 2)"},
+		{`(define (loop n) (receive (a) (values n) (if (= a 0) 'done (loop (- a 1))))) (loop 100000)`, "done"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(let-values (((a b) (values 1 2 3))) a)`, "let-values: expected 2 values but got 3"},
+		{`(receive (a b . rest) 1 a)`, "receive: expected at least 2 values but got 1"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+// test memoized promises
+func TestEval24(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(define count 0) (define p (delay (begin (set! count (+ count 1)) 'v)))
+		  (list (force p) (force p) (force p) count)`, "(v v v 1)"},
+		// the promise returning a promise is collapsed
+		{`(define count 0) (define q (delay (begin (set! count (+ count 1)) 10))) (define p (delay q))
+		  (list (force p) (force q) (force p) count)`, "(10 10 10 1)"},
+		{`(define count 0) (define q (delay (begin (set! count (+ count 1)) 10))) (define p (delay q))
+		  (list (force q) (force p) count)`, "(10 10 1)"},
+		// the value forced first is kept if the promise is forced by itself, see R7RS 4.2.5
+		{`(define count 0)
+		  (define p (delay (begin (set! count (+ count 1)) (if (> count x) count (force p)))))
+		  (define x 5)
+		  (list (force p) (begin (set! x 10) (force p)))`, "(6 6)"},
+		{`(define (loop n) (delay (if (= n 0) 'done (loop (- n 1))))) (force (loop 100000))`, "done"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
+
+// test delay-force and make-promise
+func TestEval25(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(force (make-promise 5))`, "5"},
+		{`(promise? (make-promise 5))`, "#t"},
+		{`(define p (delay 1)) (eq? p (make-promise p))`, "#t"},
+		{`(force (delay-force (delay (+ 1 2))))`, "3"},
+		{`(force (lazy (delay 'lazy)))`, "lazy"},
+		{`(define (loop n) (delay-force (if (= n 0) (make-promise 'done) (loop (- n 1)))))
+		  (force (loop 100000))`, "done"},
+		// walk a long stream built by delay-force
+		{`(define (integers n) (delay (cons n (integers (+ n 1)))))
+		  (define (stream-drop s k) (delay-force (if (= k 0) s (stream-drop (cdr (force s)) (- k 1)))))
+		  (car (force (stream-drop (integers 0) 100000)))`, "100000"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
+
+func TestEval26(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(eval '(+ 1 2) (interaction-environment))`, "3"},
+		{`(define x 10) (eval '(* x 2) (interaction-environment))`, "20"},
+		{`(eval '(define y 5) (interaction-environment)) y`, "5"},
+		{`(let ((x 1)) (eval 'x (interaction-environment)))`, "unbound"},
+		{`(eval '(if #t 1 2) (null-environment 5))`, "1"},
+		{`(eval '(+ 1 2) (null-environment))`, "unbound"},
+		{`(eq? (interaction-environment) (interaction-environment))`, "#t"},
+		{`(interaction-environment)`, "#<environment>"},
+		{`(eval '(+ 1 2) 5)`, "eval: 5 is not an environment"},
+		{`(null-environment 4)`, "null-environment: version 4 is not supported"},
+		{`(eval (cons '+ 1))`, "symbol . unbound"},
+		{`((eval '(lambda () 1)))`, "1"},
+		{`(eval '(let () 1))`, "1"},
+		{`(eval '(define-syntax foo (syntax-rules () ((_ a) a)))) (foo 2)`, "2"},
+		{`((eval '(lambda (a . rest) rest)) 1 2 3)`, "(2 3)"},
+		{`((eval '(lambda (a . rest) rest) (interaction-environment)) 1)`, "()"},
+		{`(eval '())`, "()"},
+		{`(define c (list 'begin 1)) (set-cdr! (cdr c) c) (eval c)`, "eval: circular list"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		if err != nil {
+			assert.Contains(t, err.Error(), c.expected, c.input)
+			continue
+		}
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
+
+// TestEvalDatum checks eval keeps the strings, symbols and nested data built at runtime.
+func TestEvalDatum(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(eval (list '+ 1 "two-word-string"))`, `"two-word-string" is not a number`},
+		{`(eval (list 'string-append "two word" " string"))`, `"two word string"`},
+		{`(eval (list 'string-length "a (b) \"c\""))`, "9"},
+		{`(eval (list 'quote (list "a b" 'c (vector 1 "d e"))))`, `("a b" c #(1 "d e"))`},
+		{`(eval (list 'quote (cons 1 2)))`, "(1 . 2)"},
+		{`(eval (list 'car (list 'quote (list (list "x y") 2))))`, `("x y")`},
+		{`(eval (list 'let (list (list 'x "a b")) (list 'string-length 'x)))`, "3"},
+		{`(eval (list 'quote 1 2))`, "is not a valid quote expression"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		if err != nil {
+			assert.Contains(t, err.Error(), c.expected, c.input)
+			continue
+		}
+		assert.Equal(t, c.expected, writeString(ret), c.input)
+	}
+}
+
 func TestIsSyntaxExpression(t *testing.T) {
 	assert.Equal(t, true, IsSyntaxExpression([]Expression{"begin"}))
 }
@@ -344,3 +891,22 @@ func strToToken(input string) []Expression {
 	expressions, _ := Parse(&tokens)
 	return expressions
 }
+
+// captureStdout returns what fn writes to the standard output.
+func captureStdout(fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
@@ -0,0 +1,151 @@
+package goscheme
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HashTable represents the mutable hash table in scheme, the keys are compared in the sense of equal?.
+// Should only use with pointer.
+type HashTable struct {
+	entries map[string]hashEntry
+}
+
+// hashEntry keeps the original key along with the value, the map is indexed by the canonical key.
+type hashEntry struct {
+	key, value Expression
+}
+
+// NewHashTable returns an empty *HashTable.
+func NewHashTable() *HashTable {
+	return &HashTable{entries: make(map[string]hashEntry)}
+}
+
+// String returns the string representing the hash table.
+func (h *HashTable) String() string {
+	return fmt.Sprintf("#<hash-table %d>", len(h.entries))
+}
+
+// hashKey returns the canonical key of the value, the values equal in the sense of equal? have the same key.
+// The values without structure like procedures are keyed by identity.
+func hashKey(exp Expression) string {
+	switch v := exp.(type) {
+	case Integer:
+		return "i:" + v.String()
+	case *big.Int:
+		return "i:" + v.String()
+	case *big.Rat:
+		return "r:" + v.RatString()
+	case Number:
+		return "n:" + v.String()
+	case String:
+		return "s:" + strconv.Quote(string(v))
+	case Quote:
+		return "q:" + string(v)
+	case Char:
+		return "c:" + string(rune(v))
+	case bool:
+		return "b:" + strconv.FormatBool(v)
+	case NilType:
+		return "()"
+	case *Pair:
+		return "(" + hashKey(v.Car) + " . " + hashKey(v.Cdr) + ")"
+	case *Vector:
+		keys := make([]string, len(v.Elements))
+		for i, e := range v.Elements {
+			keys[i] = hashKey(e)
+		}
+		return "#(" + strings.Join(keys, " ") + ")"
+	case *LambdaProcess, *Thunk, *HashTable, *Continuation, *Syntax:
+		return fmt.Sprintf("p:%p", v)
+	default:
+		return fmt.Sprintf("%T:%v", v, v)
+	}
+}
+
+func expressionToHashTable(exp Expression) (*HashTable, error) {
+	h, ok := exp.(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a hash table", valueToString(exp))
+	}
+	return h, nil
+}
+
+func makeHashTableFunc(_ ...Expression) (Expression, error) {
+	return NewHashTable(), nil
+}
+
+func isHashTableFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(*HashTable)
+	return ok, nil
+}
+
+func hashTableSetFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	h.entries[hashKey(args[1])] = hashEntry{args[1], args[2]}
+	return UndefObj, nil
+}
+
+// hashTableRefFunc returns the value of the key, the default value is returned if the key is missing.
+// It is an error to look up the missing key without the default value.
+func hashTableRefFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	if entry, ok := h.entries[hashKey(args[1])]; ok {
+		return entry.value, nil
+	}
+	if len(args) > 2 {
+		return args[2], nil
+	}
+	return UndefObj, fmt.Errorf("hash-table-ref: no value associated with %v", valueToString(args[1]))
+}
+
+func hashTableDeleteFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	delete(h.entries, hashKey(args[1]))
+	return UndefObj, nil
+}
+
+func hashTableCountFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Integer(len(h.entries)), nil
+}
+
+// hashTableKeysFunc returns the list of keys in unspecified order.
+func hashTableKeysFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	keys := make([]Expression, 0, len(h.entries))
+	for _, entry := range h.entries {
+		keys = append(keys, entry.key)
+	}
+	return listImpl(keys...)
+}
+
+// hashTableValuesFunc returns the list of values in unspecified order.
+func hashTableValuesFunc(args ...Expression) (Expression, error) {
+	h, err := expressionToHashTable(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	values := make([]Expression, 0, len(h.entries))
+	for _, entry := range h.entries {
+		values = append(values, entry.value)
+	}
+	return listImpl(values...)
+}
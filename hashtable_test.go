@@ -0,0 +1,55 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHashTable(t *testing.T) {
+	setup := `(define h (make-hash-table))
+		(hash-table-set! h 'a 1)
+		(hash-table-set! h "b" 2)
+		(hash-table-set! h '(1 2) 3)
+		(hash-table-set! h 1 4)
+		(hash-table-set! h 1.0 5)
+		(hash-table-set! h #(1 x) 6)`
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"(hash-table-ref h 'a)", Integer(1)},
+		{`(hash-table-ref h "b")`, Integer(2)},
+		{"(hash-table-ref h (list 1 2))", Integer(3)},
+		{"(hash-table-ref h 1)", Integer(4)},
+		{"(hash-table-ref h 1.0)", Integer(5)},
+		{"(hash-table-ref h (vector 1 'x))", Integer(6)},
+		{"(hash-table-ref h 'missing 0)", Integer(0)},
+		{"(hash-table-count h)", Integer(6)},
+		{"(hash-table-set! h 'a 10) (hash-table-ref h 'a)", Integer(10)},
+		{"(hash-table-delete! h 'a) (hash-table-ref h 'a #f)", false},
+		{"(hash-table-delete! h 'a) (hash-table-count h)", Integer(5)},
+		{"(hash-table? h)", true},
+		{"(hash-table? '())", false},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		EvalAll(strToToken(setup), env)
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+}
+
+func TestHashTableKeysAndValues(t *testing.T) {
+	env := setupBuiltinEnv()
+	EvalAll(strToToken("(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-set! h 'b 2)"), env)
+	keys, err := EvalAll(strToToken("(hash-table-keys h)"), env)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []Expression{Quote("a"), Quote("b")}, extractList(keys))
+	values, err := EvalAll(strToToken("(hash-table-values h)"), env)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []Expression{Integer(1), Integer(2)}, extractList(values))
+
+	_, err = EvalAll(strToToken("(hash-table-ref h 'c)"), env)
+	assert.EqualError(t, err, "hash-table-ref: no value associated with c")
+}
@@ -19,16 +19,19 @@ type Tokenizer struct {
 	EOF          bool
 	currentCh    rune
 	currentToken string
+	// the position of currentCh and the current token
+	line, column  int
+	tokenPosition Position
 }
 
 // NewTokenizerFromString construct *Tokenizer from string
 func NewTokenizerFromString(input string) *Tokenizer {
-	return &Tokenizer{Source: bufio.NewReader(strings.NewReader(input)), currentCh: -1}
+	return &Tokenizer{Source: bufio.NewReader(strings.NewReader(input)), currentCh: -1, line: 1}
 }
 
 // NewTokenizerFromReader construct *Tokenizer from io.Reader
 func NewTokenizerFromReader(input io.Reader) *Tokenizer {
-	return &Tokenizer{Source: bufio.NewReader(input), currentCh: -1}
+	return &Tokenizer{Source: bufio.NewReader(input), currentCh: -1, line: 1}
 }
 
 func (t *Tokenizer) readAhead() {
@@ -40,6 +43,12 @@ func (t *Tokenizer) readAhead() {
 		t.EOF = true
 		return
 	}
+	if t.currentCh == '\n' {
+		t.line++
+		t.column = 1
+	} else {
+		t.column++
+	}
 	t.currentCh = r
 }
 
@@ -79,12 +88,22 @@ func (t *Tokenizer) readSymbol() (string, bool) {
 	for !t.EOF && isSymbolCh(t.currentCh) {
 		buf = append(buf, t.currentCh)
 		t.readAhead()
+		if string(buf) == `#\` && !t.EOF {
+			// character literal accepts any character after #\, e.g. #\( or #\space
+			buf = append(buf, t.currentCh)
+			t.readAhead()
+		}
+	}
+	if string(buf) == "#" && !t.EOF && t.currentCh == '(' {
+		// the prefix of vector literal #(...)
+		t.readAhead()
+		return "#(", true
 	}
 	return string(buf), true
 }
 
 func isSymbolCh(r rune) bool {
-	return !unicode.IsSpace(r) && !strings.ContainsRune("()'", r)
+	return !unicode.IsSpace(r) && !strings.ContainsRune("()'`,", r)
 }
 
 func (t *Tokenizer) skipComment() {
@@ -99,6 +118,54 @@ func (t *Tokenizer) skipComment() {
 	}
 }
 
+// peek returns the character after currentCh without reading it, or 0 if there is none.
+func (t *Tokenizer) peek() rune {
+	b, err := t.Source.Peek(1)
+	if err != nil {
+		return 0
+	}
+	return rune(b[0])
+}
+
+// skipBlockComment skips the block comment #| ... |#, the nested block comments are skipped together.
+func (t *Tokenizer) skipBlockComment() {
+	depth := 0
+	for !t.EOF {
+		switch {
+		case t.currentCh == '#' && t.peek() == '|':
+			depth++
+			t.readAhead()
+		case t.currentCh == '|' && t.peek() == '#':
+			depth--
+			t.readAhead()
+		}
+		t.readAhead()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// skipDatum skips the tokens of the next datum, which is commented out by the datum comment #;.
+func (t *Tokenizer) skipDatum() {
+	depth := 0
+	for {
+		token, ok := t.readNextToken()
+		if !ok {
+			return
+		}
+		switch token {
+		case "(", "#(":
+			depth++
+		case ")":
+			depth--
+		}
+		if _, isPrefix := quoteAbbreviations[token]; depth <= 0 && !isPrefix {
+			return
+		}
+	}
+}
+
 func (t *Tokenizer) readNextToken() (string, bool) {
 
 	if t.EOF {
@@ -121,6 +188,17 @@ func (t *Tokenizer) readNextToken() (string, bool) {
 		t.skipComment()
 		return t.readNextToken()
 	}
+	if t.currentCh == '#' && t.peek() == '|' {
+		t.skipBlockComment()
+		return t.readNextToken()
+	}
+	if t.currentCh == '#' && t.peek() == ';' {
+		t.readAhead()
+		t.readAhead()
+		t.skipDatum()
+		return t.readNextToken()
+	}
+	t.tokenPosition = Position{t.line, t.column}
 	if t.currentCh == '"' {
 		return t.readString()
 	}
@@ -139,6 +217,18 @@ func (t *Tokenizer) readNextToken() (string, bool) {
 		t.readAhead()
 		return "'", true
 	}
+	if t.currentCh == '`' {
+		t.readAhead()
+		return "`", true
+	}
+	if t.currentCh == ',' {
+		t.readAhead()
+		if !t.EOF && t.currentCh == '@' {
+			t.readAhead()
+			return ",@", true
+		}
+		return ",", true
+	}
 	return "", false
 }
 
@@ -149,13 +239,26 @@ func (t *Tokenizer) NextToken() (string, bool) {
 	return t.currentToken, ok
 }
 
+// Position returns the source position of the current token.
+func (t *Tokenizer) Position() Position {
+	return t.tokenPosition
+}
+
 // Tokens returns all the tokens
 func (t *Tokenizer) Tokens() []string {
-	var ret []string
+	tokens, _ := t.TokensWithPositions()
+	return tokens
+}
+
+// TokensWithPositions returns all the tokens and their source positions.
+func (t *Tokenizer) TokensWithPositions() ([]string, []Position) {
+	var tokens []string
+	var positions []Position
 	token, ok := t.NextToken()
 	for ok {
-		ret = append(ret, token)
+		tokens = append(tokens, token)
+		positions = append(positions, t.Position())
 		token, ok = t.NextToken()
 	}
-	return ret
+	return tokens, positions
 }
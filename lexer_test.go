@@ -31,6 +31,18 @@ func TestTokenize(t *testing.T) {
 		{"'x()", []string{"'", "x", "(", ")"}},
 		{"' x", []string{"'", "x"}},
 		{"\"'x\"", []string{`"'x"`}},
+		{"`(1 ,x ,@y)", []string{"`", "(", "1", ",", "x", ",@", "y", ")"}},
+		{"`x,y", []string{"`", "x", ",", "y"}},
+		{`(#\( #\a #\space)`, []string{"(", `#\(`, `#\a`, `#\space`, ")"}},
+		{"#(1 #t)", []string{"#(", "1", "#t", ")"}},
+		{"(1 #| comment |# 2)", []string{"(", "1", "2", ")"}},
+		{"#| outer #| inner |# still (comment |# x", []string{"x"}},
+		{"(a #|\nline\n|#)", []string{"(", "a", ")"}},
+		{"#||#x", []string{"x"}},
+		{"(1 #;(a b c) 2)", []string{"(", "1", "2", ")"}},
+		{"#;x y", []string{"y"}},
+		{"(1 #;'(a (b)) #;#;2 3 4)", []string{"(", "1", "4", ")"}},
+		{"#|a|# #;b", nil},
 	}
 	for _, c := range testCases {
 		assert.Equal(t, c.expected, Tokenize(c.input))
@@ -56,6 +68,17 @@ func TestParse(t *testing.T) {
 		{[]string{"'", "x", "(", ")"}, []Expression{[]Expression{"quote", "x"}, []Expression{}}, nil},
 		{[]string{"'", "(", "x", ")"}, []Expression{[]Expression{"quote", []Expression{"x"}}}, nil},
 		{[]string{"'", "(", "x", ")"}, []Expression{[]Expression{"quote", []Expression{"x"}}}, nil},
+		{[]string{"'", "'", "x"}, []Expression{[]Expression{"quote", []Expression{"quote", "x"}}}, nil},
+		{[]string{"`", "(", "x", ",", "y", ",@", "z", ")"},
+			[]Expression{[]Expression{"quasiquote",
+				[]Expression{"x", []Expression{"unquote", "y"}, []Expression{"unquote-splicing", "z"}}}}, nil},
+		{[]string{"(", "a", ".", "b", ")"}, []Expression{[]Expression{"a", ".", "b"}}, nil},
+		{[]string{"(", "a", ".", "(", "b", ")", ")"}, []Expression{[]Expression{"a", ".", []Expression{"b"}}}, nil},
+		{[]string{"(", "a", ".", "b", "c", ")"}, nil, errors.New("syntax error: more than one datum after '.'")},
+		{[]string{"(", ".", "b", ")"}, nil, errors.New("syntax error: unexpected '.'")},
+		{[]string{"(", "a", ".", ")"}, nil, errors.New("syntax error: missing datum after '.'")},
+		{[]string{"."}, nil, errors.New("syntax error: unexpected '.'")},
+		{[]string{"#(", "a", ".", "b", ")"}, nil, errors.New("syntax error: unexpected '.' in vector")},
 	}
 	for _, c := range testCases {
 		ret, err := Parse(&c.input)
@@ -66,3 +89,10 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, c.expected, ret)
 	}
 }
+
+func TestTokensWithPositions(t *testing.T) {
+	tokenizer := NewTokenizerFromString("(define x 1)\n  ; comment\n  'x \"a\nb\" y")
+	tokens, positions := tokenizer.TokensWithPositions()
+	assert.Equal(t, []string{"(", "define", "x", "1", ")", "'", "x", "\"a\nb\"", "y"}, tokens)
+	assert.Equal(t, []Position{{1, 1}, {1, 2}, {1, 9}, {1, 11}, {1, 12}, {3, 3}, {3, 4}, {3, 6}, {4, 4}}, positions)
+}
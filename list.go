@@ -0,0 +1,445 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// errStopWalking is returned by the callback of walkLists to stop walking without error.
+var errStopWalking = errors.New("stop walking")
+
+// walkLists calls fn with the corresponding elements of the lists until the shortest list ends.
+func walkLists(name string, lists []Expression, fn func(elements []Expression) error) error {
+	for _, l := range lists {
+		if !isList(l) {
+			return fmt.Errorf("%s: %v is not a list", name, valueToString(l))
+		}
+	}
+	cursors := make([]Expression, len(lists))
+	copy(cursors, lists)
+	for {
+		elements := make([]Expression, len(cursors))
+		for i, cursor := range cursors {
+			p, ok := cursor.(*Pair)
+			if !ok || p.IsNull() {
+				return nil
+			}
+			elements[i] = p.Car
+			cursors[i] = p.Cdr
+		}
+		if err := fn(elements); err == errStopWalking {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// mapFunc applies the procedure to the corresponding elements of the lists and returns the list of results.
+func mapFunc(env *Env, args ...Expression) (Expression, error) {
+	var results []Expression
+	err := walkLists("map", args[1:], func(elements []Expression) error {
+		ret, err := callProcedure(env, args[0], elements...)
+		if err != nil {
+			return err
+		}
+		results = append(results, ret)
+		return nil
+	})
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(results...)
+}
+
+// forEachFunc applies the procedure to the corresponding elements of the lists from left to right for the side
+// effects.
+func forEachFunc(env *Env, args ...Expression) (Expression, error) {
+	err := walkLists("for-each", args[1:], func(elements []Expression) error {
+		_, err := callProcedure(env, args[0], elements...)
+		return err
+	})
+	return UndefObj, err
+}
+
+// partitionList splits the list into the elements satisfying the predicate and the others, the order of elements
+// is preserved.
+func partitionList(env *Env, name string, predicate, list Expression) (in, out []Expression, err error) {
+	err = walkLists(name, []Expression{list}, func(elements []Expression) error {
+		ret, err := callProcedure(env, predicate, elements[0])
+		if err != nil {
+			return err
+		}
+		if IsTrue(ret) {
+			in = append(in, elements[0])
+		} else {
+			out = append(out, elements[0])
+		}
+		return nil
+	})
+	return
+}
+
+func filterFunc(env *Env, args ...Expression) (Expression, error) {
+	in, _, err := partitionList(env, "filter", args[0], args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(in...)
+}
+
+func removeFunc(env *Env, args ...Expression) (Expression, error) {
+	_, out, err := partitionList(env, "remove", args[0], args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(out...)
+}
+
+// partitionFunc returns the elements satisfying the predicate and the others as two values.
+func partitionFunc(env *Env, args ...Expression) (Expression, error) {
+	in, out, err := partitionList(env, "partition", args[0], args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	inList, _ := listImpl(in...)
+	outList, _ := listImpl(out...)
+	return Values{inList, outList}, nil
+}
+
+// assocList returns the first pair in the association list whose car is equivalent to key in the sense of equal,
+// #f is returned if there is no such pair.
+func assocList(name string, key, alist Expression, equal func(a, b Expression) bool) (Expression, error) {
+	var found Expression = false
+	err := walkLists(name, []Expression{alist}, func(elements []Expression) error {
+		p, ok := elements[0].(*Pair)
+		if !ok {
+			return fmt.Errorf("%s: %v is not a pair", name, valueToString(elements[0]))
+		}
+		if equal(key, p.Car) {
+			found = p
+			return errStopWalking
+		}
+		return nil
+	})
+	if err != nil {
+		return UndefObj, err
+	}
+	return found, nil
+}
+
+func assqFunc(args ...Expression) (Expression, error) {
+	return assocList("assq", args[0], args[1], isEq)
+}
+
+func assvFunc(args ...Expression) (Expression, error) {
+	return assocList("assv", args[0], args[1], isEqv)
+}
+
+func assocFunc(args ...Expression) (Expression, error) {
+	return assocList("assoc", args[0], args[1], isEqual)
+}
+
+// memberList returns the sublist of list starting with the first element equivalent to x in the sense of equal,
+// #f is returned if there is no such element.
+func memberList(name string, x, list Expression, equal func(a, b Expression) bool) (Expression, error) {
+	if !isList(list) {
+		return UndefObj, fmt.Errorf("%s: %v is not a list", name, valueToString(list))
+	}
+	for p, ok := list.(*Pair); ok && !p.IsNull(); p, ok = p.Cdr.(*Pair) {
+		if equal(x, p.Car) {
+			return p, nil
+		}
+	}
+	return false, nil
+}
+
+func memqFunc(args ...Expression) (Expression, error) {
+	return memberList("memq", args[0], args[1], isEq)
+}
+
+func memvFunc(args ...Expression) (Expression, error) {
+	return memberList("memv", args[0], args[1], isEqv)
+}
+
+func memberFunc(args ...Expression) (Expression, error) {
+	return memberList("member", args[0], args[1], isEqual)
+}
+
+// sortFunc returns a new list sorted stably by the comparator, which returns true if the first argument should
+// be placed before the second one.
+func sortFunc(env *Env, args ...Expression) (Expression, error) {
+	if !isList(args[0]) {
+		return UndefObj, fmt.Errorf("sort: %v is not a list", valueToString(args[0]))
+	}
+	elements := extractList(args[0])
+	var err error
+	sort.SliceStable(elements, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var ret Expression
+		ret, err = callProcedure(env, args[1], elements[i], elements[j])
+		return err == nil && IsTrue(ret)
+	})
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(elements...)
+}
+
+// listLength returns the length of the proper list, the improper or circular list is an error.
+func listLength(name string, list Expression) (int, error) {
+	n := 0
+	slow, fast := list, list
+	for {
+		p, ok := fast.(*Pair)
+		if !ok || p.IsNull() {
+			break
+		}
+		fast, n = p.Cdr, n+1
+		if n%2 == 0 {
+			slow = slow.(*Pair).Cdr
+			if slow == fast {
+				return 0, fmt.Errorf("%s: circular list", name)
+			}
+		}
+	}
+	if !IsNullExp(fast) {
+		return 0, fmt.Errorf("%s: %v is not a proper list", name, valueToString(list))
+	}
+	return n, nil
+}
+
+func lengthFunc(args ...Expression) (Expression, error) {
+	n, err := listLength("length", args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Integer(n), nil
+}
+
+func reverseFunc(args ...Expression) (Expression, error) {
+	if _, err := listLength("reverse", args[0]); err != nil {
+		return UndefObj, err
+	}
+	var ret Expression = NilObj
+	for p, ok := args[0].(*Pair); ok && !p.IsNull(); p, ok = p.Cdr.(*Pair) {
+		ret = &Pair{p.Car, ret}
+	}
+	return ret, nil
+}
+
+// listTail returns the sublist of list after dropping k elements. If isRef is true, k is the index of element
+// which must be less than the length of list.
+func listTail(name string, list, index Expression, isRef bool) (Expression, error) {
+	n, err := listLength(name, list)
+	if err != nil {
+		return UndefObj, err
+	}
+	k, ok := index.(Integer)
+	if !ok {
+		return UndefObj, fmt.Errorf("%s: %v is not an exact integer", name, valueToString(index))
+	}
+	if k < 0 || int(k) > n || (isRef && int(k) == n) {
+		return UndefObj, fmt.Errorf("%s: index %v out of range for list of length %d", name, k, n)
+	}
+	for ; k > 0; k-- {
+		list = list.(*Pair).Cdr
+	}
+	return list, nil
+}
+
+func listRefFunc(args ...Expression) (Expression, error) {
+	tail, err := listTail("list-ref", args[0], args[1], true)
+	if err != nil {
+		return UndefObj, err
+	}
+	return tail.(*Pair).Car, nil
+}
+
+func listTailFunc(args ...Expression) (Expression, error) {
+	return listTail("list-tail", args[0], args[1], false)
+}
+
+// consStarFunc conses the leading arguments onto the last argument, the result is improper if the last argument is
+// not a list.
+func consStarFunc(args ...Expression) (Expression, error) {
+	ret := args[len(args)-1]
+	for i := len(args) - 2; i >= 0; i-- {
+		ret = &Pair{args[i], ret}
+	}
+	return ret, nil
+}
+
+// makeListFunc returns the list of k copies of fill, fill defaults to the unspecified value.
+func makeListFunc(args ...Expression) (Expression, error) {
+	k, ok := args[0].(Integer)
+	if !ok || k < 0 {
+		return UndefObj, fmt.Errorf("make-list: %v is not a valid length", valueToString(args[0]))
+	}
+	var fill Expression = UndefObj
+	if len(args) > 1 {
+		fill = args[1]
+	}
+	var ret Expression = NilObj
+	for ; k > 0; k-- {
+		ret = &Pair{fill, ret}
+	}
+	return ret, nil
+}
+
+// listCopyFunc returns the copy of the pairs of the list, the tail of the improper list is shared.
+func listCopyFunc(args ...Expression) (Expression, error) {
+	head := &Pair{}
+	last := head
+	var p Expression = args[0]
+	for pair, ok := p.(*Pair); ok && !pair.IsNull(); pair, ok = p.(*Pair) {
+		next := &Pair{pair.Car, NilObj}
+		last.Cdr, last = next, next
+		p = pair.Cdr
+	}
+	if last == head {
+		return args[0], nil
+	}
+	last.Cdr = p
+	return head.Cdr, nil
+}
+
+// lastPair returns the last pair of the non-empty list, whose cdr is the empty list or the tail of the improper list.
+func lastPair(name string, list Expression) (*Pair, error) {
+	p, ok := list.(*Pair)
+	if !ok || p.IsNull() {
+		return nil, fmt.Errorf("%s: %v is not a non-empty list", name, valueToString(list))
+	}
+	for next, ok := p.Cdr.(*Pair); ok && !next.IsNull(); next, ok = p.Cdr.(*Pair) {
+		p = next
+	}
+	return p, nil
+}
+
+func lastPairFunc(args ...Expression) (Expression, error) {
+	p, err := lastPair("last-pair", args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return p, nil
+}
+
+func lastFunc(args ...Expression) (Expression, error) {
+	p, err := lastPair("last", args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return p.Car, nil
+}
+
+// takeFunc returns the list of the first k elements of the list.
+func takeFunc(args ...Expression) (Expression, error) {
+	if _, err := listTail("take", args[0], args[1], false); err != nil {
+		return UndefObj, err
+	}
+	elements := make([]Expression, args[1].(Integer))
+	p := args[0]
+	for i := range elements {
+		elements[i] = p.(*Pair).Car
+		p = p.(*Pair).Cdr
+	}
+	return listImpl(elements...)
+}
+
+func dropFunc(args ...Expression) (Expression, error) {
+	return listTail("drop", args[0], args[1], false)
+}
+
+// listIndexFunc returns the index of the first corresponding elements of the lists satisfying the predicate, or #f
+// if there is none.
+func listIndexFunc(env *Env, args ...Expression) (Expression, error) {
+	var index Expression = false
+	i := 0
+	err := walkLists("list-index", args[1:], func(elements []Expression) error {
+		ret, err := callProcedure(env, args[0], elements...)
+		if err != nil {
+			return err
+		}
+		if IsTrue(ret) {
+			index = Integer(i)
+			return errStopWalking
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return UndefObj, err
+	}
+	return index, nil
+}
+
+// pairAccessor returns the composed accessor named c[ad]+r, which applies car for a and cdr for d from right to
+// left, e.g. cadr is (car (cdr x)).
+func pairAccessor(name string) func(args ...Expression) (Expression, error) {
+	path := name[1 : len(name)-1]
+	return func(args ...Expression) (Expression, error) {
+		v := args[0]
+		for i := len(path) - 1; i >= 0; i-- {
+			p, ok := v.(*Pair)
+			if !ok && i == len(path)-1 {
+				return UndefObj, fmt.Errorf("%s: %v is not a pair", name, valueToString(v))
+			}
+			if !ok {
+				return UndefObj, fmt.Errorf("%s: the c%sr of %v is not a pair", name, path[i+1:],
+					valueToString(args[0]))
+			}
+			if path[i] == 'a' {
+				v = p.Car
+			} else {
+				v = p.Cdr
+			}
+		}
+		return v, nil
+	}
+}
+
+// pairAccessors returns the composed accessors of two to four levels from caar to cddddr.
+func pairAccessors() map[Symbol]Function {
+	accessors := make(map[Symbol]Function)
+	paths := []string{"a", "d"}
+	for level := 2; level <= 4; level++ {
+		var next []string
+		for _, path := range paths {
+			next = append(next, "a"+path, "d"+path)
+		}
+		paths = next
+		for _, path := range paths {
+			name := "c" + path + "r"
+			accessors[Symbol(name)] = NewFunction(name, pairAccessor(name), 1, 1)
+		}
+	}
+	return accessors
+}
+
+// iotaFunc returns the list of count numbers from start by step, start and step default to 0 and 1.
+func iotaFunc(args ...Expression) (Expression, error) {
+	count, ok := args[0].(Integer)
+	if !ok || count < 0 {
+		return UndefObj, fmt.Errorf("iota: %v is not a valid count", valueToString(args[0]))
+	}
+	var start, step Expression = Integer(0), Integer(1)
+	var err error
+	if len(args) > 1 {
+		if start, err = expressionToNumber(args[1]); err != nil {
+			return UndefObj, err
+		}
+	}
+	if len(args) > 2 {
+		if step, err = expressionToNumber(args[2]); err != nil {
+			return UndefObj, err
+		}
+	}
+	numbers := make([]Expression, count)
+	for i := range numbers {
+		numbers[i] = addNumbers(start, mulNumbers(Integer(i), step))
+	}
+	return listImpl(numbers...)
+}
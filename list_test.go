@@ -0,0 +1,426 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(map + '(1 2 3) '(4 5 6))", "(5 7 9)"},
+		{"(map (lambda (x) (* x x)) '(1 2 3))", "(1 4 9)"},
+		{"(map car '((1 2) (3 4)))", "(1 3)"},
+		{"(map + '(1 2 3) '(10 20))", "(11 22)"},
+		{"(map list '(1 2) '(a b) '(x y))", "((1 a x) (2 b y))"},
+		{"(map (lambda (x) x) '())", "()"},
+		{"(define (add a b) (+ a b)) (map add '(1 2) '(3 4))", "(4 6)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(map car 1)"), env)
+	assert.EqualError(t, err, "map: 1 is not a list")
+}
+
+func TestForEach(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(define acc '()) (for-each (lambda (x) (set! acc (cons x acc))) '(a b c)) acc", "(c b a)"},
+		{"(define acc '()) (for-each (lambda (x y) (set! acc (cons (+ x y) acc))) '(1 2 3) '(10 20)) acc", "(22 11)"},
+		{"(for-each display '())", "#<void>"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(filter odd? '(1 2 3 4 5))", "(1 3 5)"},
+		{"(filter (lambda (x) (> x 10)) '(1 2 3))", "()"},
+		{"(remove odd? '(1 2 3 4 5))", "(2 4)"},
+		{"(partition odd? '(1 2 3 4 5))", "(1 3 5) (2 4)"},
+		{`(call-with-values (lambda () (partition string? (list "a" 1 "b" 2))) (lambda (strs others) others))`, "(1 2)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		EvalAll(strToToken("(define (odd? n) (= (remainder n 2) 1))"), env)
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
+
+func TestAssoc(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(assoc 2 (list (cons 1 'a) (cons 2 'b)))", "(2 . b)"},
+		{"(assoc '(a) '(((a) 1) ((b) 2)))", "((a) 1)"},
+		{"(assq '(a) '(((a) 1) ((b) 2)))", "#f"},
+		{"(assq 'b '((a 1) (b 2)))", "(b 2)"},
+		{"(assq 'c '((a 1) (b 2)))", "#f"},
+		{"(assv 2.0 '((1 one) (2.0 two)))", "(2.0 two)"},
+		{"(assv 2 '((1 one) (2.0 two)))", "#f"},
+		{"(assoc 1 '())", "#f"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(assq 'a '(1))"), env)
+	assert.EqualError(t, err, "assq: 1 is not a pair")
+}
+
+func TestMember(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"(member 3 '(1 2 3 4))", &Pair{Integer(3), &Pair{Integer(4), NilObj}}},
+		{"(member 5 '(1 2 3 4))", false},
+		{"(member '(a) '(b (a) c))", &Pair{&Pair{Quote("a"), NilObj}, &Pair{Quote("c"), NilObj}}},
+		{"(memq '(a) '(b (a) c))", false},
+		{"(memq 'c '(a b c))", &Pair{Quote("c"), NilObj}},
+		{"(memq 'd '(a b c))", false},
+		{"(memv 1.5 '(1 1.5 2))", &Pair{Number(1.5), &Pair{Integer(2), NilObj}}},
+		{"(memv 1 '(1.0 2))", false},
+		{"(member 1 '())", false},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestSort(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(sort '(3 1 2) <)", "(1 2 3)"},
+		{"(sort '(3 1 2) >)", "(3 2 1)"},
+		{"(sort '() <)", "()"},
+		{"(sort '((b 2) (a 1) (c 1)) (lambda (x y) (< (cadr x) (cadr y))))", "((a 1) (c 1) (b 2))"},
+		{"(define l '(2 1)) (sort l <) l", "(2 1)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(sort '(1 a) <)"), env)
+	assert.NotNil(t, err)
+}
+
+func TestAppend(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(append '(1 2) '(3) '(4 5))", "(1 2 3 4 5)"},
+		{"(append)", "()"},
+		{"(append '(1))", "(1)"},
+		{"(append '() 5)", "5"},
+		{"(append '(1) 2)", "(1 . 2)"},
+		{"(define x '(3)) (eq? (cdr (append '(1) x)) x)", "#t"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(append 1 '(2))"), env)
+	assert.EqualError(t, err, "append: 1 is not a list")
+}
+
+func TestLengthAndReverse(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(length '(1 2 3))", "3"},
+		{"(length '())", "0"},
+		{"(reverse '(1 (2 3) 4))", "(4 (2 3) 1)"},
+		{"(reverse '())", "()"},
+		{"(define l '(1 2)) (reverse l) l", "(1 2)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(length (cons 1 2))", "length: (1 . 2) is not a proper list"},
+		{"(define l (list 1 2 3)) (set-cdr! (cdr (cdr l)) l) (length l)", "length: circular list"},
+		{"(define l (list 1)) (set-cdr! l l) (length l)", "length: circular list"},
+		{"(reverse 1)", "reverse: 1 is not a proper list"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestListRefAndTail(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(list-ref '(a b c) 1)", "b"},
+		{"(list-ref '(a b c) 0)", "a"},
+		{"(list-tail '(a b c) 1)", "(b c)"},
+		{"(list-tail '(a b c) 3)", "()"},
+		{"(list-tail '(a b c) 0)", "(a b c)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(list-ref '(a b c) 3)", "list-ref: index 3 out of range for list of length 3"},
+		{"(list-ref '(a b c) -1)", "list-ref: index -1 out of range for list of length 3"},
+		{"(list-tail '(a b c) 4)", "list-tail: index 4 out of range for list of length 3"},
+		{"(list-ref '(a b c) 1.0)", "list-ref: 1.0 is not an exact integer"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestIota(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"(iota 5)", &Pair{Integer(0), &Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), &Pair{Integer(4), NilObj}}}}}},
+		{"(iota 3 10 2)", &Pair{Integer(10), &Pair{Integer(12), &Pair{Integer(14), NilObj}}}},
+		{"(iota 3 1)", &Pair{Integer(1), &Pair{Integer(2), &Pair{Integer(3), NilObj}}}},
+		{"(iota 2 0 -1)", &Pair{Integer(0), &Pair{Integer(-1), NilObj}}},
+		{"(iota 3 0 0.5)", &Pair{Number(0), &Pair{Number(0.5), &Pair{Number(1), NilObj}}}},
+		{"(iota 0)", NilObj},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(iota -1)"), env)
+	assert.EqualError(t, err, "iota: -1 is not a valid count")
+}
+
+func TestConsStarAndMakeList(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(cons* 1 2 '(3 4))", "(1 2 3 4)"},
+		{"(cons* 1 2 3)", "(1 2 . 3)"},
+		{"(cons* 1)", "1"},
+		{"(list* 'a '())", "(a)"},
+		{"(make-list 3 'x)", "(x x x)"},
+		{"(make-list 0 'x)", "()"},
+		{"(length (make-list 2))", "2"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken("(make-list -1)"), env)
+	assert.EqualError(t, err, "make-list: -1 is not a valid length")
+}
+
+func TestListCopyAndLast(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(list-copy '(1 2 3))", "(1 2 3)"},
+		{"(list-copy '())", "()"},
+		{"(list-copy (cons* 1 2 3))", "(1 2 . 3)"},
+		{"(define a (list 1 2 3)) (define b (list-copy a)) (set-car! b 9) (list a b (eq? a b))", "((1 2 3) (9 2 3) #f)"},
+		{"(last-pair '(1 2 3))", "(3)"},
+		{"(last-pair (cons* 1 2 3))", "(2 . 3)"},
+		{"(last '(1 2 3))", "3"},
+		{"(last '(1))", "1"},
+		{"(define l (list 1 2)) (set-cdr! (last-pair l) (list 3)) l", "(1 2 3)"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(last '())", "last: () is not a non-empty list"},
+		{"(last-pair '())", "last-pair: () is not a non-empty list"},
+		{"(last 1)", "last: 1 is not a non-empty list"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestTakeDropAndListIndex(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(take '(1 2 3 4) 2)", "(1 2)"},
+		{"(take '(1 2 3 4) 0)", "()"},
+		{"(take '(1 2 3 4) 4)", "(1 2 3 4)"},
+		{"(drop '(1 2 3 4) 2)", "(3 4)"},
+		{"(drop '(1 2 3 4) 4)", "()"},
+		{"(list-index (lambda (x) (> x 2)) '(1 2 3 4))", "2"},
+		{"(list-index (lambda (x) (> x 5)) '(1 2 3 4))", "#f"},
+		{"(list-index < '(3 2 1) '(1 2 3))", "2"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(take '(1 2 3) 4)", "take: index 4 out of range for list of length 3"},
+		{"(drop '(1 2 3) 4)", "drop: index 4 out of range for list of length 3"},
+		{"(take '(1 2 3) 'a)", "take: a is not an exact integer"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestPairAccessors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(car '(1 2 3))", "1"},
+		{"(cdr '(1 2 3))", "(2 3)"},
+		{"(cadr '(1 2 3))", "2"},
+		{"(cddr '(1 2 3))", "(3)"},
+		{"(caddr '(1 2 3))", "3"},
+		{"(cdddr '(1 2 3))", "()"},
+		{"(caar '((1) 2))", "1"},
+		{"(cdar '((1 . 4) 2))", "4"},
+		{"(cadddr '(1 2 3 4))", "4"},
+		{"(caaaar '((((a)))))", "a"},
+		{"(cddddr '(1 2 3 4 5))", "(5)"},
+		{"(cadr 1)", "cadr: 1 is not a pair"},
+		{"(caddr '(1 2))", "caddr: the cddr of (1 2) is not a pair"},
+		{"(caar '(1))", "caar: the car of (1) is not a pair"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		if err != nil {
+			assert.Contains(t, err.Error(), c.expected, c.input)
+			continue
+		}
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+	env := setupBuiltinEnv()
+	for _, name := range []string{"caar", "cdddr", "cadadr", "cddddr"} {
+		_, err := env.Find(Symbol(name))
+		assert.Nil(t, err, name)
+	}
+	_, err := env.Find("caaaaar")
+	assert.NotNil(t, err)
+}
+
+func TestSetCarAndSetCdr(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(define p (list 1 2)) (set-car! p 9) p", "(9 2)"},
+		{"(define p (list 1 2)) (set-cdr! p 3) p", "(1 . 3)"},
+		// the pair shared by two variables
+		{"(define a (list 1 2)) (define b a) (set-car! b 'x) (set-cdr! (cdr a) '(3)) (list a b (eq? a b))",
+			"((x 2 3) (x 2 3) #t)"},
+		{"(define x (list 1 2)) (define y (list 0 x)) (set-car! x 'changed) y", "(0 (changed 2))"},
+		// a queue keeping the pointers to the front and rear pairs
+		{`(define q (cons '() '()))
+		  (define (enqueue! q x)
+		    (let ((cell (list x)))
+		      (if (null? (car q)) (set-car! q cell) (set-cdr! (cdr q) cell))
+		      (set-cdr! q cell)))
+		  (enqueue! q 1) (enqueue! q 2) (enqueue! q 3)
+		  (car q)`, "(1 2 3)"},
+		// the circular list
+		{"(define c (list 1 2)) (set-cdr! (cdr c) c) (list (car c) (cadr c) (caddr c) (eq? c (cddr c)))",
+			"(1 2 1 #t)"},
+		{"(set-car! (if #f #f) 1)", "set-car!: #<void> is not a pair"},
+		{"(set-cdr! '() 1)", "set-cdr!: () is not a pair"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		if err != nil {
+			assert.Contains(t, err.Error(), c.expected, c.input)
+			continue
+		}
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
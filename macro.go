@@ -0,0 +1,399 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const ellipsis = "..."
+
+var gensymCounter uint64
+
+// gensym returns a new symbol name based on the prefix which will not conflict with the existed symbols. The name
+// is never read as a number, e.g. the prefix + gives +%1.
+func gensym(prefix string) string {
+	return fmt.Sprintf("%s%%%d", prefix, atomic.AddUint64(&gensymCounter, 1))
+}
+
+// expander is implemented by the macros, which transform the expression before evaluation. The expanded expression
+// is evaluated in the returned environment.
+type expander interface {
+	Expand(exp []Expression, env *Env) (Expression, *Env, error)
+}
+
+// Macro represents the unhygienic macro defined by define-macro. The procedure receives the unevaluated operands
+// and returns the expression to evaluate.
+type Macro struct {
+	name      string
+	procedure *LambdaProcess
+}
+
+// String returns the string to display representing the macro.
+func (m *Macro) String() string {
+	return fmt.Sprintf("#[Macro %s]", m.name)
+}
+
+// Expand calls the procedure with the operands as data and transforms the result back to expression.
+func (m *Macro) Expand(exp []Expression, env *Env) (Expression, *Env, error) {
+	var args []Expression
+	for _, operand := range exp[1:] {
+		arg, err := evalQuote([]Expression{operand}, env)
+		if err != nil {
+			return UndefObj, env, err
+		}
+		args = append(args, arg)
+	}
+	ret, err := callProcedure(env, m.procedure, args...)
+	if err != nil {
+		return UndefObj, env, err
+	}
+	expanded, err := datumToExpression(ret)
+	return expanded, env, err
+}
+
+// evalDefineMacro binds the macro to the name, supports both (define-macro (name args...) body...) and
+// (define-macro name procedure).
+func evalDefineMacro(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("define-macro: syntax error (requires name and body)")
+	}
+	var name Symbol
+	var procedure Expression
+	var err error
+	switch signature := args[0].(type) {
+	case []Expression:
+		if len(signature) < 1 {
+			return UndefObj, errors.New("define-macro: syntax error (missing name)")
+		}
+		if name, err = transExpressionToSymbol(signature[0]); err != nil {
+			return UndefObj, err
+		}
+		procedure, err = evalLambda(append([]Expression{signature[1:]}, args[1:]...), env)
+	default:
+		if name, err = transExpressionToSymbol(signature); err != nil {
+			return UndefObj, err
+		}
+		procedure, err = Eval(args[1], env)
+	}
+	if err != nil {
+		return UndefObj, err
+	}
+	p, ok := procedure.(*LambdaProcess)
+	if !ok {
+		return UndefObj, fmt.Errorf("define-macro: %v is not a procedure", valueToString(procedure))
+	}
+	env.Set(name, &Macro{string(name), p})
+	return UndefObj, nil
+}
+
+// syntaxRule is a pair of pattern and template of syntax-rules.
+type syntaxRule struct {
+	pattern  []Expression
+	template Expression
+}
+
+// SyntaxRules represents the macro defined by define-syntax and syntax-rules.
+type SyntaxRules struct {
+	name     string
+	literals map[string]bool
+	rules    []syntaxRule
+	// the environment defining the macro, the free identifiers of the templates refer to its bindings
+	env *Env
+}
+
+// String returns the string to display representing the macro.
+func (s *SyntaxRules) String() string {
+	return fmt.Sprintf("#[Macro %s]", s.name)
+}
+
+// Expand matches the expression with the rules and returns the expanded expression of the first matched rule. The
+// expansion is evaluated in the environment which resolves the renamed identifiers, see aliasScope.
+func (s *SyntaxRules) Expand(exp []Expression, env *Env) (Expression, *Env, error) {
+	for _, rule := range s.rules {
+		bindings := make(map[string]Expression)
+		// the keyword position of pattern is ignored
+		if !s.match(rule.pattern[1:], exp[1:], bindings) {
+			continue
+		}
+		renames := make(map[string]string)
+		ret, err := s.expand(rule.template, bindings, renames)
+		if err != nil || len(renames) == 0 {
+			return ret, env, err
+		}
+		scope := &aliasScope{originals: make(map[Symbol]Symbol, len(renames)), env: s.env}
+		for original, renamed := range renames {
+			scope.originals[Symbol(renamed)] = Symbol(original)
+		}
+		return ret, env.withAliases(scope), nil
+	}
+	return UndefObj, env, fmt.Errorf("%s: no matching syntax rule for %s", s.name, expToPrintString(exp))
+}
+
+// ellipsisMatch holds the bindings of a pattern variable which is followed by ellipsis, one for each matched input.
+type ellipsisMatch []Expression
+
+func (s *SyntaxRules) match(pattern Expression, input Expression, bindings map[string]Expression) bool {
+	switch p := pattern.(type) {
+	case []Expression:
+		items, ok := input.([]Expression)
+		if !ok {
+			return false
+		}
+		return s.matchList(p, items, bindings)
+	case string:
+		if s.literals[p] {
+			return input == p
+		}
+		if IsSymbol(p) {
+			if p != "_" {
+				bindings[p] = input
+			}
+			return true
+		}
+		return input == p
+	default:
+		return false
+	}
+}
+
+func (s *SyntaxRules) matchList(pattern []Expression, input []Expression, bindings map[string]Expression) bool {
+	for i, p := range pattern {
+		if i+1 < len(pattern) && pattern[i+1] == ellipsis {
+			// the patterns after the ellipsis match the tail of input
+			tailCount := len(pattern) - i - 2
+			repeatCount := len(input) - i - tailCount
+			if repeatCount < 0 {
+				return false
+			}
+			var matches []map[string]Expression
+			for _, item := range input[i : i+repeatCount] {
+				m := make(map[string]Expression)
+				if !s.match(p, item, m) {
+					return false
+				}
+				matches = append(matches, m)
+			}
+			for _, v := range s.patternVars(p) {
+				var values ellipsisMatch
+				for _, m := range matches {
+					values = append(values, m[v])
+				}
+				bindings[v] = values
+			}
+			return s.matchList(pattern[i+2:], input[i+repeatCount:], bindings)
+		}
+		if i >= len(input) || !s.match(p, input[i], bindings) {
+			return false
+		}
+	}
+	return len(pattern) == len(input)
+}
+
+// patternVars returns the pattern variables in pattern.
+func (s *SyntaxRules) patternVars(pattern Expression) (ret []string) {
+	switch p := pattern.(type) {
+	case []Expression:
+		for _, e := range p {
+			ret = append(ret, s.patternVars(e)...)
+		}
+	case string:
+		if p != ellipsis && p != "_" && !s.literals[p] && IsSymbol(p) {
+			ret = append(ret, p)
+		}
+	}
+	return
+}
+
+func (s *SyntaxRules) expand(template Expression, bindings map[string]Expression, renames map[string]string) (Expression, error) {
+	switch t := template.(type) {
+	case []Expression:
+		if len(t) == 2 && t[0] == "quote" {
+			// quoted symbols are data, substitute the pattern variables only
+			datum, err := s.expand(t[1], bindings, nil)
+			if err != nil {
+				return UndefObj, err
+			}
+			return []Expression{"quote", datum}, nil
+		}
+		var ret []Expression
+		for i := 0; i < len(t); i++ {
+			if i+1 < len(t) && t[i+1] == ellipsis {
+				items, err := s.expandEllipsis(t[i], bindings, renames)
+				if err != nil {
+					return UndefObj, err
+				}
+				ret = append(ret, items...)
+				i++
+				continue
+			}
+			item, err := s.expand(t[i], bindings, renames)
+			if err != nil {
+				return UndefObj, err
+			}
+			ret = append(ret, item)
+		}
+		if ret == nil {
+			ret = []Expression{}
+		}
+		return ret, nil
+	case string:
+		if v, ok := bindings[t]; ok {
+			if _, ok := v.(ellipsisMatch); ok {
+				return UndefObj, fmt.Errorf("%s: pattern variable %s should be followed by ellipsis", s.name, t)
+			}
+			return v, nil
+		}
+		if renames == nil {
+			return t, nil
+		}
+		return s.rename(t, renames), nil
+	default:
+		return t, nil
+	}
+}
+
+func (s *SyntaxRules) expandEllipsis(template Expression, bindings map[string]Expression, renames map[string]string) ([]Expression, error) {
+	var vars []string
+	count := -1
+	for _, v := range s.patternVars(template) {
+		values, ok := bindings[v].(ellipsisMatch)
+		if !ok {
+			continue
+		}
+		if count != -1 && count != len(values) {
+			return nil, fmt.Errorf("%s: pattern variables followed by ellipsis matched different lengths", s.name)
+		}
+		count = len(values)
+		vars = append(vars, v)
+	}
+	if len(vars) == 0 {
+		return nil, errors.New(s.name + ": no pattern variable before ellipsis in template")
+	}
+	var ret []Expression
+	for i := 0; i < count; i++ {
+		b := make(map[string]Expression, len(bindings))
+		for k, v := range bindings {
+			b[k] = v
+		}
+		for _, v := range vars {
+			b[v] = bindings[v].(ellipsisMatch)[i]
+		}
+		item, err := s.expand(template, b, renames)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+// auxiliaryKeywords are the symbols which are recognized by the syntax by name, they should not be renamed.
+var auxiliaryKeywords = map[string]bool{"else": true, "=>": true, "_": true, ellipsis: true}
+
+// rename keeps the hygiene of macro, the identifiers introduced by the template are renamed so they will not
+// capture the variables of the caller. The free identifiers still refer to the bindings of the environment defining
+// the macro, see lookupSymbol.
+func (s *SyntaxRules) rename(symbol string, renames map[string]string) string {
+	if !IsSymbol(symbol) || auxiliaryKeywords[symbol] || s.literals[symbol] {
+		return symbol
+	}
+	if _, ok := SyntaxMap[symbol]; ok {
+		return symbol
+	}
+	if newName, ok := renames[symbol]; ok {
+		return newName
+	}
+	renames[symbol] = gensym(symbol)
+	return renames[symbol]
+}
+
+// aliasScope maps the identifiers renamed by a macro expansion to the original ones, which refer to the bindings of
+// the environment defining the macro. It is attached to the environment evaluating the expansion and inherited by
+// the environments extended from it, so it lives as long as the code of the expansion.
+type aliasScope struct {
+	originals map[Symbol]Symbol
+	env       *Env
+	// the scope of the enclosing expansion
+	outer *aliasScope
+}
+
+// withAliases returns the environment sharing the bindings of e, in which the identifiers renamed by the expansion
+// of scope are resolved.
+func (e *Env) withAliases(scope *aliasScope) *Env {
+	scope.outer = e.aliases
+	view := e.view()
+	view.aliases = scope
+	return view
+}
+
+// resolveAlias returns the original identifier of the renamed one and the environment defining the macro.
+func (e *Env) resolveAlias(symbol Symbol) (Symbol, *Env, bool) {
+	for scope := e.aliases; scope != nil; scope = scope.outer {
+		if original, ok := scope.originals[symbol]; ok {
+			return original, scope.env, true
+		}
+	}
+	return symbol, nil, false
+}
+
+// lookupSymbol finds the value of symbol in env. The renamed identifier which is not bound by the macro expansion
+// refers to the binding of the original identifier in the environment defining the macro.
+func lookupSymbol(symbol Symbol, env *Env) (Expression, error) {
+	ret, err := env.Find(symbol)
+	if err == nil {
+		return ret, nil
+	}
+	if original, definition, ok := env.resolveAlias(symbol); ok {
+		return lookupSymbol(original, definition)
+	}
+	return ret, err
+}
+
+// evalDefineSyntax binds the macro defined by syntax-rules to the name.
+func evalDefineSyntax(args []Expression, env *Env) (Expression, error) {
+	if len(args) != 2 {
+		return UndefObj, errors.New("define-syntax: syntax error (requires name and syntax-rules)")
+	}
+	sym, err := transExpressionToSymbol(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	spec, ok := args[1].([]Expression)
+	if !ok || len(spec) < 2 || spec[0] != "syntax-rules" {
+		return UndefObj, errors.New("define-syntax: syntax error (only syntax-rules is supported)")
+	}
+	macro, err := makeSyntaxRules(string(sym), spec[1:], env)
+	if err != nil {
+		return UndefObj, err
+	}
+	env.Set(sym, macro)
+	return UndefObj, nil
+}
+
+func makeSyntaxRules(name string, spec []Expression, env *Env) (*SyntaxRules, error) {
+	literals, ok := spec[0].([]Expression)
+	if !ok {
+		return nil, errors.New("syntax-rules: syntax error (literals should be a list)")
+	}
+	macro := &SyntaxRules{name: name, literals: make(map[string]bool), env: env}
+	for _, l := range literals {
+		sym, err := transExpressionToSymbol(l)
+		if err != nil {
+			return nil, err
+		}
+		macro.literals[string(sym)] = true
+	}
+	for _, exp := range spec[1:] {
+		rule, ok := exp.([]Expression)
+		if !ok || len(rule) != 2 {
+			return nil, errors.New("syntax-rules: syntax error (rule should be a pattern and a template)")
+		}
+		pattern, ok := rule[0].([]Expression)
+		if !ok || len(pattern) < 1 {
+			return nil, errors.New("syntax-rules: syntax error (pattern should be a list)")
+		}
+		macro.rules = append(macro.rules, syntaxRule{pattern, rule[1]})
+	}
+	return macro, nil
+}
@@ -0,0 +1,117 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSyntaxRules(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(define-syntax swap!
+				(syntax-rules ()
+					((_ a b) (let ((tmp a)) (set! a b) (set! b tmp)))))
+			(define x 1)
+			(define y 2)
+			(swap! x y)
+			(list x y)`, &Pair{Integer(2), &Pair{Integer(1), NilObj}}},
+		// introduced identifier should not capture the variable of caller
+		{`(define-syntax swap!
+				(syntax-rules ()
+					((_ a b) (let ((tmp a)) (set! a b) (set! b tmp)))))
+			(define (f tmp y) (swap! tmp y) (list tmp y))
+			(f 1 2)`, &Pair{Integer(2), &Pair{Integer(1), NilObj}}},
+		{`(define-syntax my-or
+				(syntax-rules ()
+					((_) #f)
+					((_ e) e)
+					((_ e r ...) (let ((t e)) (if t t (my-or r ...))))))
+			(define t 5)
+			(list (my-or) (my-or #f t) (my-or #f #f 3))`, &Pair{false, &Pair{Integer(5), &Pair{Integer(3), NilObj}}}},
+		{`(define-syntax my-let
+				(syntax-rules ()
+					((_ ((name val) ...) body1 body2 ...) ((lambda (name ...) body1 body2 ...) val ...))))
+			(my-let ((a 1) (b 2)) (+ a b))`, Integer(3)},
+		{`(define-syntax for
+				(syntax-rules (in)
+					((_ x in lst body) (map (lambda (x) body) lst))))
+			(for x in '(1 2 3) (* x x))`, &Pair{Integer(1), &Pair{Integer(4), &Pair{Integer(9), NilObj}}}},
+		// the introduced + and - are not read as numbers
+		{`(define-syntax inc! (syntax-rules () ((_ v) (set! v (+ v 1)))))
+			(define-syntax dec! (syntax-rules () ((_ v) (set! v (- v 1)))))
+			(define n 0)
+			(inc! n) (inc! n) (dec! n)
+			n`, Integer(1)},
+		// the free identifiers of template refer to the bindings where the macro is defined
+		{`(define-syntax mk (syntax-rules () ((_ x) (list x))))
+			(let ((list vector)) (mk 1))`, &Pair{Integer(1), NilObj}},
+		{`(define (make-counter)
+				(let ((count 0))
+					(define-syntax next! (syntax-rules () ((_) (begin (set! count (+ count 1)) count))))
+					(lambda () (next!))))
+			(define c (make-counter))
+			(c)
+			(let ((count 10)) (c))`, Integer(2)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken(`
+			(define-syntax for
+				(syntax-rules (in)
+					((_ x in lst body) (map (lambda (x) body) lst))))
+			(for x on '(1 2 3) x)`), env)
+	assert.NotNil(t, err)
+
+	// only the identifiers renamed by the expansion refer to the original bindings
+	for _, input := range []string{
+		`(define x 5) x.1`,
+		`(define x 5) x%1`,
+		`(define x 5) (set! x.1 6)`,
+		`(define g 5) (eval (gensym "g") (interaction-environment))`,
+	} {
+		_, err := EvalAll(strToToken(input), setupBuiltinEnv())
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestDefineMacro(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(define-macro (my-unless c body) (list 'if c ''() body))
+			(my-unless #f 3)`, Integer(3)},
+		{"(define-macro (swap! a b) (let ((tmp (gensym))) `(let ((,tmp ,a)) (set! ,a ,b) (set! ,b ,tmp))))" + `
+			(define tmp 1)
+			(define y 2)
+			(swap! tmp y)
+			(list tmp y)`, &Pair{Integer(2), &Pair{Integer(1), NilObj}}},
+		{`(define-macro my-quote (lambda (x) (list 'quote x)))
+			(my-quote (a b))`, &Pair{Quote("a"), &Pair{Quote("b"), NilObj}}},
+		{`(define-macro (while c body)
+				(list 'let 'loop '() (list 'when c body '(loop))))
+			(define i 0)
+			(while (< i 5) (set! i (+ i 1)))
+			i`, Integer(5)},
+		// the dotted parameter list of the expansion
+		{"(define-macro (mkv name) `(define (,name . args) args))" + `
+			(mkv f)
+			(f 1 2)`, &Pair{Integer(1), &Pair{Integer(2), NilObj}}},
+		{"(define-macro (mkl) `(lambda (a . rest) (cons rest a)))" + `
+			((mkl) 1 2)`, &Pair{&Pair{Integer(2), NilObj}, Integer(1)}},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+}
@@ -0,0 +1,980 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Integer represents the exact integer in scheme.
+type Integer int64
+
+// String returns the decimal form of the integer.
+func (i Integer) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+// String returns the string representing the inexact number, the integral value keeps the decimal point to be
+// distinguished from the exact Integer.
+func (n Number) String() string {
+	f := float64(n)
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	case math.IsNaN(f):
+		return "+nan.0"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// the levels of numeric tower, the operands are converted to the same level before calculation.
+// The exact integer out of the range of int64 is represented as *big.Int, and the exact rational number is
+// represented as *big.Rat.
+const (
+	integerLevel = iota
+	bigIntegerLevel
+	rationalLevel
+	realLevel
+)
+
+func numberLevel(n Expression) int {
+	switch n.(type) {
+	case Integer:
+		return integerLevel
+	case *big.Int:
+		return bigIntegerLevel
+	case *big.Rat:
+		return rationalLevel
+	default:
+		return realLevel
+	}
+}
+
+// convertNumber converts the number to the higher level of numeric tower.
+func convertNumber(n Expression, level int) Expression {
+	switch level {
+	case bigIntegerLevel:
+		if i, ok := n.(Integer); ok {
+			return big.NewInt(int64(i))
+		}
+	case rationalLevel:
+		switch v := n.(type) {
+		case Integer:
+			return new(big.Rat).SetInt64(int64(v))
+		case *big.Int:
+			return new(big.Rat).SetInt(v)
+		}
+	case realLevel:
+		return Number(toFloat(n))
+	}
+	return n
+}
+
+func toFloat(n Expression) float64 {
+	switch v := n.(type) {
+	case Integer:
+		return float64(v)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	case Number:
+		return float64(v)
+	default:
+		return math.NaN()
+	}
+}
+
+// normalizeBigInt returns the Integer if the big integer fits in int64.
+func normalizeBigInt(i *big.Int) Expression {
+	if i.IsInt64() {
+		return Integer(i.Int64())
+	}
+	return i
+}
+
+// normalizeRat returns the integer if the denominator of rational number is 1.
+func normalizeRat(r *big.Rat) Expression {
+	if r.IsInt() {
+		return normalizeBigInt(new(big.Int).Set(r.Num()))
+	}
+	return r
+}
+
+// coerceNumbers converts the two numbers to the same level of numeric tower.
+func coerceNumbers(a, b Expression) (Expression, Expression) {
+	level := numberLevel(a)
+	if l := numberLevel(b); l > level {
+		level = l
+	}
+	return convertNumber(a, level), convertNumber(b, level)
+}
+
+func isExact(n Expression) bool {
+	return numberLevel(n) < realLevel
+}
+
+func addNumbers(a, b Expression) Expression {
+	a, b = coerceNumbers(a, b)
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if ret := x + y; (ret > x) == (y > 0) {
+			return ret
+		}
+		return addNumbers(big.NewInt(int64(x)), y)
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Add(x, b.(*big.Int)))
+	case *big.Rat:
+		return normalizeRat(new(big.Rat).Add(x, b.(*big.Rat)))
+	default:
+		return a.(Number) + b.(Number)
+	}
+}
+
+func subNumbers(a, b Expression) Expression {
+	a, b = coerceNumbers(a, b)
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if ret := x - y; (ret < x) == (y > 0) {
+			return ret
+		}
+		return subNumbers(big.NewInt(int64(x)), y)
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Sub(x, b.(*big.Int)))
+	case *big.Rat:
+		return normalizeRat(new(big.Rat).Sub(x, b.(*big.Rat)))
+	default:
+		return a.(Number) - b.(Number)
+	}
+}
+
+func mulNumbers(a, b Expression) Expression {
+	a, b = coerceNumbers(a, b)
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if x == 0 || y == 0 {
+			return Integer(0)
+		}
+		if ret := x * y; ret/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64) {
+			return ret
+		}
+		return mulNumbers(big.NewInt(int64(x)), y)
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Mul(x, b.(*big.Int)))
+	case *big.Rat:
+		return normalizeRat(new(big.Rat).Mul(x, b.(*big.Rat)))
+	default:
+		return a.(Number) * b.(Number)
+	}
+}
+
+// divNumbers divides the numbers, the quotient of exact numbers is an exact rational number in lowest terms.
+func divNumbers(a, b Expression) (Expression, error) {
+	a, b = coerceNumbers(a, b)
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if y == 0 {
+			return UndefObj, errors.New("/: division by zero")
+		}
+		if x%y == 0 && !(x == math.MinInt64 && y == -1) {
+			return x / y, nil
+		}
+		return normalizeRat(big.NewRat(int64(x), int64(y))), nil
+	case *big.Int:
+		y := b.(*big.Int)
+		if y.Sign() == 0 {
+			return UndefObj, errors.New("/: division by zero")
+		}
+		return normalizeRat(new(big.Rat).SetFrac(x, y)), nil
+	case *big.Rat:
+		y := b.(*big.Rat)
+		if y.Sign() == 0 {
+			return UndefObj, errors.New("/: division by zero")
+		}
+		return normalizeRat(new(big.Rat).Quo(x, y)), nil
+	default:
+		return a.(Number) / b.(Number), nil
+	}
+}
+
+// maxExactExptBits bounds the size of the exact result of expt, so a huge exponent raises an error instead of
+// exhausting the memory.
+const maxExactExptBits = 1 << 24
+
+// exptNumbers raises base to the power exponent, the result is exact if base is exact and exponent is an exact
+// integer. Raising exact zero to a negative power is an error like the division by zero.
+func exptNumbers(base, exponent Expression) (Expression, error) {
+	if isExact(base) && numberLevel(exponent) <= bigIntegerLevel {
+		e, _ := convertNumber(exponent, bigIntegerLevel).(*big.Int)
+		b, _ := convertNumber(base, rationalLevel).(*big.Rat)
+		if e.Sign() < 0 && b.Sign() == 0 {
+			return UndefObj, errors.New("expt: division by zero")
+		}
+		abs := new(big.Int).Abs(e)
+		bits := b.Num().BitLen()
+		if b.Denom().BitLen() > bits {
+			bits = b.Denom().BitLen()
+		}
+		// the magnitude of base other than 0 and 1 grows the result by at least bits-1 bits each time
+		if bits > 1 && (!abs.IsInt64() || abs.Int64() > maxExactExptBits/int64(bits-1)) {
+			return UndefObj, fmt.Errorf("expt: exponent %v is too large", valueToString(exponent))
+		}
+		num := new(big.Int).Exp(b.Num(), abs, nil)
+		denom := new(big.Int).Exp(b.Denom(), abs, nil)
+		if e.Sign() < 0 {
+			num, denom = denom, num
+		}
+		return normalizeRat(new(big.Rat).SetFrac(num, denom)), nil
+	}
+	return Number(math.Pow(toFloat(base), toFloat(exponent))), nil
+}
+
+// absNumber returns the magnitude of the number with the exactness preserved.
+func absNumber(n Expression) Expression {
+	switch v := n.(type) {
+	case Integer:
+		if v == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(int64(v)))
+		}
+		if v < 0 {
+			return -v
+		}
+		return v
+	case *big.Int:
+		return new(big.Int).Abs(v)
+	case *big.Rat:
+		return new(big.Rat).Abs(v)
+	default:
+		return Number(math.Abs(toFloat(n)))
+	}
+}
+
+// exactSqrt returns the exact square root of the non-negative exact number if the numerator and denominator are
+// both perfect squares.
+func exactSqrt(n Expression) (Expression, bool) {
+	r, _ := convertNumber(n, rationalLevel).(*big.Rat)
+	num := new(big.Int).Sqrt(r.Num())
+	denom := new(big.Int).Sqrt(r.Denom())
+	if new(big.Int).Mul(num, num).Cmp(r.Num()) != 0 || new(big.Int).Mul(denom, denom).Cmp(r.Denom()) != 0 {
+		return nil, false
+	}
+	return normalizeRat(new(big.Rat).SetFrac(num, denom)), true
+}
+
+// compareNumbers returns -1 if a < b, 0 if a == b, 1 if a > b.
+func compareNumbers(a, b Expression) int {
+	a, b = coerceNumbers(a, b)
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case *big.Int:
+		return x.Cmp(b.(*big.Int))
+	case *big.Rat:
+		return x.Cmp(b.(*big.Rat))
+	default:
+		f, g := a.(Number), b.(Number)
+		switch {
+		case f < g:
+			return -1
+		case f > g:
+			return 1
+		}
+		return 0
+	}
+}
+
+// radixPrefixes maps the radix prefixes of the number literals, e.g. #xff, to the radix.
+var radixPrefixes = map[byte]int{'x': 16, 'o': 8, 'b': 2, 'd': 10}
+
+// hasNumberPrefix checks whether the token starts with the radix or exactness prefix of the number literals.
+func hasNumberPrefix(token string) bool {
+	return len(token) > 2 && token[0] == '#' && strings.ContainsRune("xobdei", unicode.ToLower(rune(token[1])))
+}
+
+// parseNumber parses the number literal, see numberLiteral.
+func parseNumber(token string) (Expression, error) {
+	num, ok := numberLiteral(token)
+	if !ok {
+		return UndefObj, fmt.Errorf("%v is not a number", token)
+	}
+	return num, nil
+}
+
+// numberLiteral parses the number literal, which may start with a radix prefix #x, #o, #b or #d and an exactness
+// prefix #e or #i in either order, e.g. #e#x10. Only the exact integers and rationals can be written in radix
+// other than 10.
+func numberLiteral(token string) (Expression, bool) {
+	s, radix, exactness := token, 0, byte(0)
+	for len(s) > 2 && s[0] == '#' {
+		c := byte(unicode.ToLower(rune(s[1])))
+		if r, ok := radixPrefixes[c]; ok && radix == 0 {
+			radix = r
+		} else if (c == 'e' || c == 'i') && exactness == 0 {
+			exactness = c
+		} else {
+			return nil, false
+		}
+		s = s[2:]
+	}
+	var num Expression
+	var ok bool
+	if radix == 0 || radix == 10 {
+		if num, ok = decimalLiteral(s); !ok {
+			return nil, false
+		}
+		if _, isFloat := num.(Number); isFloat && exactness == 'e' {
+			// the exact decimal is parsed as rational to avoid the rounding of float, e.g. #e0.1 is 1/10
+			if r, ok := new(big.Rat).SetString(s); ok {
+				return normalizeRat(r), true
+			}
+		}
+	} else if num, ok = parseInteger(s, radix); !ok {
+		return nil, false
+	}
+	switch exactness {
+	case 'e':
+		ret, err := exactNumber(num)
+		return ret, err == nil
+	case 'i':
+		return convertNumber(num, realLevel), true
+	}
+	return num, true
+}
+
+// decimalPattern matches the integer and decimal literals in radix 10 with optional sign and exponent, e.g. +5, .5,
+// -3.14 and 1e10. It rejects the other forms accepted by strconv.ParseFloat, e.g. 1_000, 0x1p4 and inf.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// rationalPattern matches the rational literals in radix 10, e.g. -1/3.
+var rationalPattern = regexp.MustCompile(`^[+-]?\d+/\d+$`)
+
+// decimalLiteral parses the number literal in radix 10 without prefix.
+func decimalLiteral(token string) (Expression, bool) {
+	if !strings.ContainsAny(token, "0123456789") {
+		// reject the symbols quickly
+		return nil, false
+	}
+	if i, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return Integer(i), true
+	}
+	if rationalPattern.MatchString(token) {
+		r, ok := new(big.Rat).SetString(token)
+		if !ok {
+			return nil, false
+		}
+		return normalizeRat(r), true
+	}
+	if !decimalPattern.MatchString(token) {
+		return nil, false
+	}
+	if i, ok := new(big.Int).SetString(token, 10); ok {
+		return i, true
+	}
+	// the number too large is the infinity
+	f, err := strconv.ParseFloat(token, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, false
+	}
+	return Number(f), true
+}
+
+func isExactFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return isExact(num), nil
+}
+
+func isInexactFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return !isExact(num), nil
+}
+
+// isExactIntegerFunc checks whether the argument is an exact integer, (exact-integer? 3.0) is #f.
+func isExactIntegerFunc(args ...Expression) (Expression, error) {
+	switch args[0].(type) {
+	case Integer, *big.Int:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// isIntegerFunc checks whether the argument is an integer, the inexact number with integral value like 3.0 is an
+// integer.
+func isIntegerFunc(args ...Expression) (Expression, error) {
+	switch v := args[0].(type) {
+	case Integer, *big.Int:
+		return true, nil
+	case *big.Rat:
+		return v.IsInt(), nil
+	case Number:
+		f := float64(v)
+		return !math.IsInf(f, 0) && math.Trunc(f) == f, nil
+	default:
+		return false, nil
+	}
+}
+
+// isRationalFunc checks whether the argument is a rational number, which are all the numbers except the infinities
+// and NaN.
+func isRationalFunc(args ...Expression) (Expression, error) {
+	switch v := args[0].(type) {
+	case Integer, *big.Int, *big.Rat:
+		return true, nil
+	case Number:
+		f := float64(v)
+		return !math.IsInf(f, 0) && !math.IsNaN(f), nil
+	default:
+		return false, nil
+	}
+}
+
+func exactToInexactFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return convertNumber(num, realLevel), nil
+}
+
+func inexactToExactFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	ret, err := exactNumber(num)
+	if err != nil {
+		return UndefObj, fmt.Errorf("inexact->exact: %v", err)
+	}
+	return ret, nil
+}
+
+// exactNumber returns the exact number equal to the number, the infinities and NaN have no exact representation.
+func exactNumber(num Expression) (Expression, error) {
+	if isExact(num) {
+		return num, nil
+	}
+	r := new(big.Rat).SetFloat64(toFloat(num))
+	if r == nil {
+		return UndefObj, fmt.Errorf("no exact representation of %v", num)
+	}
+	return normalizeRat(r), nil
+}
+
+func numeratorFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	switch v := num.(type) {
+	case *big.Rat:
+		return normalizeBigInt(new(big.Int).Set(v.Num())), nil
+	case Number:
+		r := new(big.Rat).SetFloat64(float64(v))
+		if r == nil {
+			return UndefObj, fmt.Errorf("numerator: %v is not a rational number", v)
+		}
+		return Number(toFloat(r.Num())), nil
+	default:
+		return num, nil
+	}
+}
+
+func denominatorFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	switch v := num.(type) {
+	case *big.Rat:
+		return normalizeBigInt(new(big.Int).Set(v.Denom())), nil
+	case Number:
+		r := new(big.Rat).SetFloat64(float64(v))
+		if r == nil {
+			return UndefObj, fmt.Errorf("denominator: %v is not a rational number", v)
+		}
+		return Number(toFloat(r.Denom())), nil
+	default:
+		return Integer(1), nil
+	}
+}
+
+func exptFunc(args ...Expression) (Expression, error) {
+	base, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	exponent, err := expressionToNumber(args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return exptNumbers(base, exponent)
+}
+
+func absFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return absNumber(num), nil
+}
+
+// sqrtFunc returns the exact root of the exact perfect square, otherwise the inexact root.
+// The complex numbers are not supported, so the square root of a negative number is an error.
+func sqrtFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	if compareNumbers(num, Integer(0)) < 0 {
+		return UndefObj, fmt.Errorf("sqrt: %v is negative", num)
+	}
+	if isExact(num) {
+		if root, ok := exactSqrt(num); ok {
+			return root, nil
+		}
+	}
+	return Number(math.Sqrt(toFloat(num))), nil
+}
+
+// signPredicate returns the builtin checking whether the sign of the number is sign.
+func signPredicate(sign int) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		num, err := expressionToNumber(args[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		return compareNumbers(num, Integer(0)) == sign, nil
+	}
+}
+
+// parityPredicate returns the builtin named name checking whether the remainder of the integer divided by 2 is
+// remainder.
+func parityPredicate(name string, remainder int) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		num, err := expressionToNumber(args[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		if !isInteger(num) {
+			return UndefObj, fmt.Errorf("%s: %v is not an integer", name, num)
+		}
+		r := absNumber(remainderNumbers(coerceNumbers(num, Integer(2))))
+		return compareNumbers(r, Integer(remainder)) == 0, nil
+	}
+}
+
+// floatFunction returns the builtin applying fn to the number, the result is always inexact.
+func floatFunction(fn func(float64) float64) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		num, err := expressionToNumber(args[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		return Number(fn(toFloat(num))), nil
+	}
+}
+
+// atanFunc returns the arc tangent of y, or the angle of the point (x, y) if x is provided.
+// (atan y [x])
+func atanFunc(args ...Expression) (Expression, error) {
+	y, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	if len(args) == 1 {
+		return Number(math.Atan(toFloat(y))), nil
+	}
+	x, err := expressionToNumber(args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Number(math.Atan2(toFloat(y), toFloat(x))), nil
+}
+
+// logFunc returns the natural logarithm of z, or the logarithm of z to the base if base is provided.
+// (log z [base])
+func logFunc(args ...Expression) (Expression, error) {
+	z, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	if len(args) == 1 {
+		return Number(math.Log(toFloat(z))), nil
+	}
+	base, err := expressionToNumber(args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Number(math.Log(toFloat(z)) / math.Log(toFloat(base))), nil
+}
+
+// isInteger checks whether the number is an integer, the inexact number with integral value is also an integer.
+func isInteger(n Expression) bool {
+	switch v := n.(type) {
+	case Integer, *big.Int:
+		return true
+	case Number:
+		return float64(v) == math.Trunc(float64(v)) && !math.IsInf(float64(v), 0)
+	default:
+		return false
+	}
+}
+
+// integerDivisionOperands checks the operands of integer division procedure named name and converts them to
+// the same level of numeric tower.
+func integerDivisionOperands(name string, args []Expression) (Expression, Expression, error) {
+	var operands [2]Expression
+	for i := range operands {
+		num, err := expressionToNumber(args[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isInteger(num) {
+			return nil, nil, fmt.Errorf("%s: %v is not an integer", name, num)
+		}
+		operands[i] = num
+	}
+	if toFloat(operands[1]) == 0 {
+		return nil, nil, fmt.Errorf("%s: division by zero", name)
+	}
+	a, b := coerceNumbers(operands[0], operands[1])
+	return a, b, nil
+}
+
+// quotientNumbers returns the integer quotient truncated towards zero.
+func quotientNumbers(a, b Expression) Expression {
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if x == math.MinInt64 && y == -1 {
+			return quotientNumbers(big.NewInt(int64(x)), big.NewInt(int64(y)))
+		}
+		return x / y
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Quo(x, b.(*big.Int)))
+	default:
+		return Number(math.Trunc(float64(a.(Number)) / float64(b.(Number))))
+	}
+}
+
+// remainderNumbers returns the remainder which has the sign of the dividend.
+func remainderNumbers(a, b Expression) Expression {
+	switch x := a.(type) {
+	case Integer:
+		y := b.(Integer)
+		if y == -1 {
+			return Integer(0)
+		}
+		return x % y
+	case *big.Int:
+		return normalizeBigInt(new(big.Int).Rem(x, b.(*big.Int)))
+	default:
+		return Number(math.Mod(float64(a.(Number)), float64(b.(Number))))
+	}
+}
+
+// moduloNumbers returns the modulo which has the sign of the divisor.
+func moduloNumbers(a, b Expression) Expression {
+	r := remainderNumbers(a, b)
+	if sign := compareNumbers(r, Integer(0)); sign != 0 && sign != compareNumbers(b, Integer(0)) {
+		return addNumbers(r, b)
+	}
+	return r
+}
+
+func quotientFunc(args ...Expression) (Expression, error) {
+	a, b, err := integerDivisionOperands("quotient", args)
+	if err != nil {
+		return UndefObj, err
+	}
+	return quotientNumbers(a, b), nil
+}
+
+func remainderFunc(args ...Expression) (Expression, error) {
+	a, b, err := integerDivisionOperands("remainder", args)
+	if err != nil {
+		return UndefObj, err
+	}
+	return remainderNumbers(a, b), nil
+}
+
+func moduloFunc(args ...Expression) (Expression, error) {
+	a, b, err := integerDivisionOperands("modulo", args)
+	if err != nil {
+		return UndefObj, err
+	}
+	return moduloNumbers(a, b), nil
+}
+
+// extremum returns the argument which is ordered first by the sign of comparison, the result is inexact if any
+// argument is inexact.
+func extremum(args []Expression, sign int) (Expression, error) {
+	var ret Expression
+	exact := true
+	for _, arg := range args {
+		num, err := expressionToNumber(arg)
+		if err != nil {
+			return UndefObj, err
+		}
+		exact = exact && isExact(num)
+		if ret == nil || compareNumbers(num, ret) == sign {
+			ret = num
+		}
+	}
+	if !exact {
+		return convertNumber(ret, realLevel), nil
+	}
+	return ret, nil
+}
+
+func maxFunc(args ...Expression) (Expression, error) {
+	return extremum(args, 1)
+}
+
+func minFunc(args ...Expression) (Expression, error) {
+	return extremum(args, -1)
+}
+
+// floorDivFunc returns the floor quotient and the modulo as multiple values.
+func floorDivFunc(args ...Expression) (Expression, error) {
+	a, b, err := integerDivisionOperands("floor/", args)
+	if err != nil {
+		return UndefObj, err
+	}
+	m := moduloNumbers(a, b)
+	return Values{quotientNumbers(subNumbers(a, m), b), m}, nil
+}
+
+// truncateDivFunc returns the truncated quotient and the remainder as multiple values.
+func truncateDivFunc(args ...Expression) (Expression, error) {
+	a, b, err := integerDivisionOperands("truncate/", args)
+	if err != nil {
+		return UndefObj, err
+	}
+	return Values{quotientNumbers(a, b), remainderNumbers(a, b)}, nil
+}
+
+// toBigInt converts the integer to *big.Int.
+func toBigInt(n Expression) *big.Int {
+	switch v := n.(type) {
+	case Integer:
+		return big.NewInt(int64(v))
+	case *big.Int:
+		return v
+	default:
+		i, _ := big.NewFloat(toFloat(n)).Int(nil)
+		return i
+	}
+}
+
+// foldIntegers folds the absolute values of integer arguments of procedure named name with fn, the result is
+// inexact if any argument is inexact.
+func foldIntegers(name string, args []Expression, init int64, fn func(acc, x *big.Int) *big.Int) (Expression, error) {
+	acc := big.NewInt(init)
+	exact := true
+	for _, arg := range args {
+		num, err := expressionToNumber(arg)
+		if err != nil {
+			return UndefObj, err
+		}
+		if !isInteger(num) {
+			return UndefObj, fmt.Errorf("%s: %v is not an integer", name, num)
+		}
+		exact = exact && isExact(num)
+		acc = fn(acc, new(big.Int).Abs(toBigInt(num)))
+	}
+	if !exact {
+		return convertNumber(acc, realLevel), nil
+	}
+	return normalizeBigInt(acc), nil
+}
+
+func gcdFunc(args ...Expression) (Expression, error) {
+	return foldIntegers("gcd", args, 0, func(acc, x *big.Int) *big.Int {
+		return new(big.Int).GCD(nil, nil, acc, x)
+	})
+}
+
+func lcmFunc(args ...Expression) (Expression, error) {
+	return foldIntegers("lcm", args, 1, func(acc, x *big.Int) *big.Int {
+		if acc.Sign() == 0 || x.Sign() == 0 {
+			return new(big.Int)
+		}
+		gcd := new(big.Int).GCD(nil, nil, acc, x)
+		return new(big.Int).Mul(new(big.Int).Quo(acc, gcd), x)
+	})
+}
+
+// roundNumber rounds the number with the rounding function of exact rationals or inexact numbers, the exact
+// integer is returned unchanged.
+func roundNumber(arg Expression, ratFn func(*big.Rat) *big.Int, floatFn func(float64) float64) (Expression, error) {
+	num, err := expressionToNumber(arg)
+	if err != nil {
+		return UndefObj, err
+	}
+	switch v := num.(type) {
+	case *big.Rat:
+		return normalizeBigInt(ratFn(v)), nil
+	case Number:
+		return Number(floatFn(float64(v))), nil
+	default:
+		return num, nil
+	}
+}
+
+// floorRat returns the largest integer not greater than r, the denominator of *big.Rat is always positive so
+// the Euclidean division rounds towards negative infinity.
+func floorRat(r *big.Rat) *big.Int {
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+func ceilingRat(r *big.Rat) *big.Int {
+	return new(big.Int).Neg(floorRat(new(big.Rat).Neg(r)))
+}
+
+func truncateRat(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// roundRat rounds r to the nearest integer, rounding to even when r is halfway between two integers.
+func roundRat(r *big.Rat) *big.Int {
+	floor := floorRat(r)
+	diff := new(big.Rat).Sub(r, new(big.Rat).SetInt(floor))
+	switch diff.Cmp(big.NewRat(1, 2)) {
+	case -1:
+		return floor
+	case 0:
+		if floor.Bit(0) == 0 {
+			return floor
+		}
+	}
+	return floor.Add(floor, big.NewInt(1))
+}
+
+func floorFunc(args ...Expression) (Expression, error) {
+	return roundNumber(args[0], floorRat, math.Floor)
+}
+
+func ceilingFunc(args ...Expression) (Expression, error) {
+	return roundNumber(args[0], ceilingRat, math.Ceil)
+}
+
+func truncateFunc(args ...Expression) (Expression, error) {
+	return roundNumber(args[0], truncateRat, math.Trunc)
+}
+
+func roundFunc(args ...Expression) (Expression, error) {
+	return roundNumber(args[0], roundRat, math.RoundToEven)
+}
+
+// radixArgument checks the optional radix argument of procedure named name, the default radix is 10.
+func radixArgument(name string, args []Expression) (int, error) {
+	if len(args) == 0 {
+		return 10, nil
+	}
+	radix, ok := args[0].(Integer)
+	if !ok || (radix != 2 && radix != 8 && radix != 10 && radix != 16) {
+		return 0, fmt.Errorf("%s: invalid radix %v, must be one of 2, 8, 10 and 16", name, valueToString(args[0]))
+	}
+	return int(radix), nil
+}
+
+// numberToStringFunc returns the string representing the number in the optional radix, only exact integers can
+// be represented in radix other than 10.
+func numberToStringFunc(args ...Expression) (Expression, error) {
+	num, err := expressionToNumber(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	radix, err := radixArgument("number->string", args[1:])
+	if err != nil {
+		return UndefObj, err
+	}
+	switch v := num.(type) {
+	case Integer:
+		return String(strconv.FormatInt(int64(v), radix)), nil
+	case *big.Int:
+		return String(v.Text(radix)), nil
+	}
+	if radix != 10 {
+		return UndefObj, fmt.Errorf("number->string: %v can only be represented in radix 10", valueToString(num))
+	}
+	return String(valueToString(num)), nil
+}
+
+// parseInteger parses the exact integer or rational number in radix.
+func parseInteger(s string, radix int) (Expression, bool) {
+	if parts := strings.SplitN(s, "/", 2); len(parts) == 2 {
+		num, ok := new(big.Int).SetString(parts[0], radix)
+		if !ok {
+			return nil, false
+		}
+		denom, ok := new(big.Int).SetString(parts[1], radix)
+		if !ok || denom.Sign() <= 0 || strings.HasPrefix(parts[1], "+") {
+			return nil, false
+		}
+		return normalizeRat(new(big.Rat).SetFrac(num, denom)), true
+	}
+	i, ok := new(big.Int).SetString(s, radix)
+	if !ok {
+		return nil, false
+	}
+	return normalizeBigInt(i), true
+}
+
+// stringToNumberFunc parses the string as a number in the optional radix, #f is returned if the string is not a
+// valid number. The numbers in radix 10 can be decimal or in exponent notation.
+func stringToNumberFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	radix, err := radixArgument("string->number", args[1:])
+	if err != nil {
+		return UndefObj, err
+	}
+	if radix != 10 {
+		if num, ok := parseInteger(string(s), radix); ok {
+			return num, nil
+		}
+		return false, nil
+	}
+	if !IsNumber(string(s)) {
+		return false, nil
+	}
+	num, err := parseNumber(string(s))
+	if err != nil {
+		return false, nil
+	}
+	return num, nil
+}
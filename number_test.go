@@ -0,0 +1,536 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func bigIntFromString(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+func TestNumber_String(t *testing.T) {
+	testCases := []struct {
+		input    Expression
+		expected string
+	}{
+		{Integer(3), "3"},
+		{Integer(-3), "-3"},
+		{Number(3), "3.0"},
+		{Number(2.5), "2.5"},
+		{Number(1e21), "1e+21"},
+		{Number(math.Inf(1)), "+inf.0"},
+	}
+	for _, c := range testCases {
+		assert.Equal(t, c.expected, valueToString(c.input))
+	}
+}
+
+func TestExactness(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(+ 1 2)`, Integer(3)},
+		{`(* 1000000000 1000000000)`, Integer(1000000000000000000)},
+		{`(+ 1 2.0)`, Number(3)},
+		{`(- 5)`, Integer(-5)},
+		{`(/ 10 2)`, Integer(5)},
+		{`(/ 10 4)`, big.NewRat(5, 2)},
+		{`(/ 10.0 2)`, Number(5)},
+		{`(= 2 2.0)`, true},
+		{`(< 1 1.5)`, true},
+		{`(exact? 1)`, true},
+		{`(exact? 1.0)`, false},
+		{`(inexact? 1.5)`, true},
+		{`(exact->inexact 1)`, Number(1)},
+		{`(inexact->exact 2.0)`, Integer(2)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+	_, err := EvalAll(strToToken(`(/ 1 0)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func TestBigInteger(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(expt 2 200)`, bigIntFromString("1606938044258990275541962092341162602522202993782792835301376")},
+		{`(expt 2 10)`, Integer(1024)},
+		{`(expt 2.0 3)`, Number(8)},
+		{`(+ 9223372036854775807 1)`, bigIntFromString("9223372036854775808")},
+		{`(- -9223372036854775808 1)`, bigIntFromString("-9223372036854775809")},
+		{`(- (+ 9223372036854775807 1) 1)`, Integer(math.MaxInt64)},
+		{`(* 4294967296 4294967296)`, bigIntFromString("18446744073709551616")},
+		{`(/ (expt 10 30) (expt 10 28))`, Integer(100)},
+		{`(= (expt 2 100) (* (expt 2 50) (expt 2 50)))`, true},
+		{`(< (expt 2 100) (expt 2 101))`, true},
+		{`(define (fact n) (if (= n 0) 1 (* n (fact (- n 1))))) (fact 25)`,
+			bigIntFromString("15511210043330985984000000")},
+		{`99999999999999999999`, bigIntFromString("99999999999999999999")},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+	assert.Equal(t, "1267650600228229401496703205376", valueToString(bigIntFromString("1267650600228229401496703205376")))
+}
+
+func TestRational(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(/ 1 3)`, big.NewRat(1, 3)},
+		{`(/ 6 4)`, big.NewRat(3, 2)},
+		{`(+ (/ 1 3) (/ 2 3))`, Integer(1)},
+		{`(* (/ 1 3) 3)`, Integer(1)},
+		{`(- (/ 1 2) 1)`, big.NewRat(-1, 2)},
+		{`(+ (/ 1 2) 0.5)`, Number(1)},
+		{`(< (/ 1 3) (/ 1 2))`, true},
+		{`(= (/ 1 2) 0.5)`, true},
+		{`(numerator (/ 6 4))`, Integer(3)},
+		{`(denominator (/ 6 4))`, Integer(2)},
+		{`(denominator 5)`, Integer(1)},
+		{`(denominator 0.5)`, Number(2)},
+		{`(inexact->exact 0.25)`, big.NewRat(1, 4)},
+		{`(exact->inexact (/ 1 4))`, Number(0.25)},
+		{`(exact? (/ 1 3))`, true},
+		{`1/3`, big.NewRat(1, 3)},
+		{`(expt (/ 1 2) 2)`, big.NewRat(1, 4)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret)
+	}
+	assert.Equal(t, "1/3", valueToString(big.NewRat(1, 3)))
+}
+
+func TestIntegerDivision(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(quotient 7 3)`, Integer(2)},
+		{`(quotient -7 3)`, Integer(-2)},
+		{`(quotient 7 -3)`, Integer(-2)},
+		{`(quotient -7 -3)`, Integer(2)},
+		{`(remainder 7 3)`, Integer(1)},
+		{`(remainder -7 3)`, Integer(-1)},
+		{`(remainder 7 -3)`, Integer(1)},
+		{`(remainder -7 -3)`, Integer(-1)},
+		{`(modulo 7 3)`, Integer(1)},
+		{`(modulo -7 3)`, Integer(2)},
+		{`(modulo 7 -3)`, Integer(-2)},
+		{`(modulo -7 -3)`, Integer(-1)},
+		{`(modulo 6 3)`, Integer(0)},
+		{`(quotient 7.0 2)`, Number(3)},
+		{`(modulo -7.0 2)`, Number(1)},
+		{`(quotient -9223372036854775808 -1)`, bigIntFromString("9223372036854775808")},
+		{`(modulo -100000000000000000000 3)`, Integer(2)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+	for _, input := range []string{`(quotient 1 0)`, `(remainder 1 0)`, `(modulo 1 0)`, `(modulo 1.5 1)`} {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(input), env)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestGcdLcm(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(gcd)`, Integer(0)},
+		{`(lcm)`, Integer(1)},
+		{`(gcd 12 18 24)`, Integer(6)},
+		{`(gcd -12 18)`, Integer(6)},
+		{`(gcd 0 5)`, Integer(5)},
+		{`(lcm 4 6)`, Integer(12)},
+		{`(lcm -4 6 5)`, Integer(60)},
+		{`(lcm 0 5)`, Integer(0)},
+		{`(gcd 4.0 6)`, Number(2)},
+		{`(gcd 200000000000000000000 300000000000000000000)`, bigIntFromString("100000000000000000000")},
+		{`(lcm 4000000000 6000000000 7000000000)`, Integer(84000000000)},
+		{`(lcm 100000000000000000000 3 2)`, bigIntFromString("300000000000000000000")},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(max 1 2.0 3)`, Number(3)},
+		{`(min 1 2.0 3)`, Number(1)},
+		{`(max 3 1 2)`, Integer(3)},
+		{`(min 3 -1 2)`, Integer(-1)},
+		{`(max 5)`, Integer(5)},
+		{`(max 1/2 1/3)`, big.NewRat(1, 2)},
+		{`(min 100000000000000000000 1)`, Integer(1)},
+		{`(max 100000000000000000000 1)`, bigIntFromString("100000000000000000000")},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(max)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+	_, err = EvalAll(strToToken(`(min 1 "a")`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func TestAbsExptSqrt(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(abs -5)`, Integer(5)},
+		{`(abs 5)`, Integer(5)},
+		{`(abs -2.5)`, Number(2.5)},
+		{`(abs -1/2)`, big.NewRat(1, 2)},
+		{`(abs -100000000000000000000)`, bigIntFromString("100000000000000000000")},
+		{`(abs -9223372036854775808)`, bigIntFromString("9223372036854775808")},
+		{`(expt 2 -2)`, big.NewRat(1, 4)},
+		{`(expt 2/3 -2)`, big.NewRat(9, 4)},
+		{`(expt 0 0)`, Integer(1)},
+		{`(expt 1 (expt 10 30))`, Integer(1)},
+		{`(expt -1 (+ (expt 10 30) 1))`, Integer(-1)},
+		{`(expt 0 (expt 10 30))`, Integer(0)},
+		{`(expt 4 0.5)`, Number(2)},
+		{`(sqrt 16)`, Integer(4)},
+		{`(sqrt 0)`, Integer(0)},
+		{`(sqrt 1/4)`, big.NewRat(1, 2)},
+		{`(sqrt 2)`, Number(math.Sqrt(2))},
+		{`(sqrt 16.0)`, Number(4)},
+		{`(sqrt (expt 10 40))`, bigIntFromString("100000000000000000000")},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(sqrt -4)`), setupBuiltinEnv())
+	assert.EqualError(t, err, "sqrt: -4 is negative")
+	_, err = EvalAll(strToToken(`(expt 0 -1)`), setupBuiltinEnv())
+	assert.EqualError(t, err, "expt: division by zero")
+	_, err = EvalAll(strToToken(`(expt 2 (expt 10 10))`), setupBuiltinEnv())
+	assert.EqualError(t, err, "expt: exponent 10000000000 is too large")
+	_, err = EvalAll(strToToken(`(expt 1/3 (- (expt 10 30)))`), setupBuiltinEnv())
+	assert.EqualError(t, err, "expt: exponent -1000000000000000000000000000000 is too large")
+}
+
+func TestTranscendental(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+	}{
+		{`(atan 1 1)`, math.Pi / 4},
+		{`(* 4 (atan 1))`, math.Pi},
+		{`(exp 0)`, 1},
+		{`(log 1)`, 0},
+		{`(log 100 10)`, 2},
+		{`(log 8 2)`, 3},
+		{`(sin 0)`, 0},
+		{`(cos 0)`, 1},
+		{`(tan 0)`, 0},
+		{`(asin 1)`, math.Pi / 2},
+		{`(acos 1)`, 0},
+		{`(exp 1/2)`, math.Exp(0.5)},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.IsType(t, Number(0), ret, c.input)
+		assert.InDelta(t, c.expected, float64(ret.(Number)), 1e-12, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(sin "a")`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+	_, err = EvalAll(strToToken(`(log 1 "a")`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func TestNumericPredicates(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(even? 4)`, true},
+		{`(even? -3)`, false},
+		{`(odd? -3)`, true},
+		{`(odd? 0)`, false},
+		{`(even? 4.0)`, true},
+		{`(odd? 100000000000000000001)`, true},
+		{`(negative? -2)`, true},
+		{`(negative? 0)`, false},
+		{`(positive? 1/2)`, true},
+		{`(positive? -0.5)`, false},
+		{`(zero? 0)`, true},
+		{`(zero? 0.0)`, true},
+		{`(zero? 1)`, false},
+		{`(define (count n) (if (zero? n) 'done (count (- n 1)))) (count 10)`, Quote("done")},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(odd? 1.5)`), setupBuiltinEnv())
+	assert.EqualError(t, err, "odd?: 1.5 is not an integer")
+	_, err = EvalAll(strToToken(`(zero? 'a)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func TestComparisonChain(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(< 1 2 3 4)`, true},
+		{`(< 1 3 2)`, false},
+		{`(< 1 1 2)`, false},
+		{`(<= 1 1 2)`, true},
+		{`(> 4 3 2 1)`, true},
+		{`(>= 3 3 4)`, false},
+		{`(= 1 1 1)`, true},
+		{`(= 1 1.0 1)`, true},
+		{`(= 1 1 2)`, false},
+		{`(apply < (list 1 2 3))`, true},
+		// the comparison stops at the first violation
+		{`(< 2 1 'a)`, false},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(< 1 2 'a)`), setupBuiltinEnv())
+	assert.NotNil(t, err)
+}
+
+func TestRounding(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(floor 2.5)`, Number(2)},
+		{`(floor -2.5)`, Number(-3)},
+		{`(ceiling 2.5)`, Number(3)},
+		{`(ceiling -2.5)`, Number(-2)},
+		{`(truncate 2.7)`, Number(2)},
+		{`(truncate -2.7)`, Number(-2)},
+		{`(round 2.5)`, Number(2)},
+		{`(round 3.5)`, Number(4)},
+		{`(round -2.5)`, Number(-2)},
+		{`(round 2.6)`, Number(3)},
+		{`(round 7)`, Integer(7)},
+		{`(floor 7)`, Integer(7)},
+		{`(floor 7/2)`, Integer(3)},
+		{`(floor -7/2)`, Integer(-4)},
+		{`(ceiling 7/2)`, Integer(4)},
+		{`(ceiling -7/2)`, Integer(-3)},
+		{`(truncate -7/2)`, Integer(-3)},
+		{`(round 5/2)`, Integer(2)},
+		{`(round 7/2)`, Integer(4)},
+		{`(round -7/2)`, Integer(-4)},
+		{`(round 7/3)`, Integer(2)},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestNumberToString(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(number->string 255)`, String("255")},
+		{`(number->string 255 16)`, String("ff")},
+		{`(number->string -255 16)`, String("-ff")},
+		{`(number->string 8 8)`, String("10")},
+		{`(number->string 5 2)`, String("101")},
+		{`(number->string 100000000000000000000 16)`, String("56bc75e2d63100000")},
+		{`(number->string 3.0)`, String("3.0")},
+		{`(number->string 2.5 10)`, String("2.5")},
+		{`(number->string 1/3)`, String("1/3")},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(number->string 2.5 16)`, "number->string: 2.5 can only be represented in radix 10"},
+		{`(number->string 1/3 2)`, "number->string: 1/3 can only be represented in radix 10"},
+		{`(number->string 10 3)`, "number->string: invalid radix 3, must be one of 2, 8, 10 and 16"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestStringToNumber(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(string->number "255")`, Integer(255)},
+		{`(string->number "-17")`, Integer(-17)},
+		{`(string->number "+17")`, Integer(17)},
+		{`(string->number "2.5")`, Number(2.5)},
+		{`(string->number "-.5")`, Number(-0.5)},
+		{`(string->number "1e3")`, Number(1000)},
+		{`(string->number "1.5E-2")`, Number(0.015)},
+		{`(string->number "1/3")`, big.NewRat(1, 3)},
+		{`(string->number "100000000000000000000")`, bigIntFromString("100000000000000000000")},
+		{`(string->number "ff" 16)`, Integer(255)},
+		{`(string->number "-FF" 16)`, Integer(-255)},
+		{`(string->number "101" 2)`, Integer(5)},
+		{`(string->number "17" 8)`, Integer(15)},
+		{`(string->number "a/c" 16)`, big.NewRat(5, 6)},
+		{`(string->number "hello")`, false},
+		{`(string->number "")`, false},
+		{`(string->number "1 2")`, false},
+		{`(string->number "12" 2)`, false},
+		{`(string->number "1.5" 16)`, false},
+		{`(string->number "1/0")`, false},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestNumberPrefixes(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"#xff", Integer(255)},
+		{"#XFF", Integer(255)},
+		{"#x1f", Integer(31)},
+		{"#o17", Integer(15)},
+		{"#b1010", Integer(10)},
+		{"#b-101", Integer(-5)},
+		{"#d99", Integer(99)},
+		{"#x1/2", big.NewRat(1, 2)},
+		{"#e1.5", big.NewRat(3, 2)},
+		{"#e0.1", big.NewRat(1, 10)},
+		{"#e1e3", Integer(1000)},
+		{"#i3", Number(3)},
+		{"#i1/4", Number(0.25)},
+		{"#e#xff", Integer(255)},
+		{"#x#eff", Integer(255)},
+		{"#i#b101", Number(5)},
+		{"'#xff", Integer(255)},
+		{`(string->number "#xff")`, Integer(255)},
+		{`(string->number "#b102")`, false},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+	for _, input := range []string{"#b102", "#o8", "#xfg", "#e#e1", "#x#b1", "#x1.5"} {
+		tokens := Tokenize(input)
+		_, err := Parse(&tokens)
+		assert.EqualError(t, err, "syntax error: invalid number "+input)
+	}
+}
+
+func TestDecimalLiterals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{"1e10", Number(1e10)},
+		{"1E10", Number(1e10)},
+		{"1.5e-3", Number(0.0015)},
+		{"+1e+2", Number(100)},
+		{"-3.14", Number(-3.14)},
+		{"+5", Integer(5)},
+		{"-5", Integer(-5)},
+		{".5", Number(0.5)},
+		{"+.5", Number(0.5)},
+		{"-.5e2", Number(-50)},
+		{"5.", Number(5)},
+		{"1e400", Number(math.Inf(1))},
+		{"(+ .5 +5)", Number(5.5)},
+		{"'(+ - ...)", &Pair{Quote("+"), &Pair{Quote("-"), &Pair{Quote("..."), NilObj}}}},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+	for _, token := range []string{"1e", "e10", "--5", "+-5", "1_000", "0x1p4", "inf", "nan", "1.2.3"} {
+		assert.False(t, IsNumber(token), token)
+	}
+}
+
+func TestNumericTypePredicates(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(list (integer? 3) (integer? 3.0) (integer? 3.5) (integer? 1/2) (integer? 100000000000000000000))",
+			"(#t #t #f #f #t)"},
+		{"(list (integer? (exp 1000)) (integer? 'a) (integer? \"3\"))", "(#f #f #f)"},
+		{"(list (exact-integer? 3) (exact-integer? 3.0) (exact-integer? 1/2) (exact-integer? 100000000000000000000))",
+			"(#t #f #f #t)"},
+		{"(list (rational? 1/2) (rational? 3) (rational? 0.5) (rational? (exp 1000)))", "(#t #t #t #f)"},
+		{"(rational? (- (exp 1000) (exp 1000)))", "#f"},
+		{"(list (real? 1) (real? 1.5) (real? 1/2) (real? (exp 1000)) (real? 'a))", "(#t #t #t #t #f)"},
+		{"(list (complex? 1) (complex? 1.5) (complex? \"1\"))", "(#t #t #f)"},
+		{"(list (exact? 3) (exact? 1/2) (exact? 3.0) (inexact? 3.0) (inexact? 3))", "(#t #t #f #t #f)"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+}
@@ -0,0 +1,319 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SetOutput sets the writer the output procedures like display write to by default, it is shared by the
+// environments extended from the same global environment. The default output is os.Stdout.
+func (e *Env) SetOutput(w io.Writer) {
+	if e.state == nil {
+		return
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.output = NewOutputPort(w)
+}
+
+// Output returns the writer the output procedures write to by default.
+func (e *Env) Output() io.Writer {
+	return e.OutputPort().writer
+}
+
+// OutputPort returns the output port the output procedures write to by default, which is the port bound by
+// with-output-to-string during the evaluation of its thunk.
+func (e *Env) OutputPort() *Port {
+	if e.dynamic != nil && e.dynamic.output != nil {
+		return e.dynamic.output
+	}
+	if e.state == nil {
+		return stdoutPort
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	if e.state.output == nil {
+		return stdoutPort
+	}
+	return e.state.output
+}
+
+// stdoutWriter writes to the current os.Stdout.
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// stdoutPort is the output port writing to os.Stdout.
+var stdoutPort = NewOutputPort(stdoutWriter{})
+
+// writeString returns the external representation of the value written by write, the strings and characters
+// are quoted so they can be read back.
+func writeString(exp Expression) string {
+	return valueToString(exp)
+}
+
+// displayString returns the representation of the value written by display, the strings and characters inside
+// are written as is.
+func displayString(exp Expression) string {
+	switch v := exp.(type) {
+	case String:
+		return string(v)
+	case Char:
+		return string(rune(v))
+	case *Pair:
+		var s []string
+		current := Expression(v)
+		for {
+			p, ok := current.(*Pair)
+			if !ok || p.IsNull() {
+				break
+			}
+			s = append(s, displayString(p.Car))
+			current = p.Cdr
+		}
+		if !IsNullExp(current) {
+			s = append(s, ".", displayString(current))
+		}
+		return "(" + strings.Join(s, " ") + ")"
+	case *Vector:
+		s := make([]string, len(v.Elements))
+		for i, e := range v.Elements {
+			s[i] = displayString(e)
+		}
+		return "#(" + strings.Join(s, " ") + ")"
+	default:
+		return valueToString(exp)
+	}
+}
+
+// outputFunctions returns the output procedures writing to the port in the optional argument or the output port
+// of the caller, the output port of the global environment is used if called outside of an evaluation.
+func outputFunctions(global *Env) map[Symbol]Function {
+	current := func(env *Env) *Port {
+		if env == nil {
+			return global.OutputPort()
+		}
+		return env.OutputPort()
+	}
+	print := func(env *Env, s string, port []Expression) error {
+		p := current(env)
+		if len(port) > 0 {
+			var err error
+			if p, err = expressionToOutputPort(port[0]); err != nil {
+				return err
+			}
+		}
+		if p.closed {
+			return fmt.Errorf("%v is closed", valueToString(p))
+		}
+		_, err := fmt.Fprint(p.writer, s)
+		return err
+	}
+	return map[Symbol]Function{
+		"display": newEnvFunction("display", func(env *Env, args ...Expression) (Expression, error) {
+			return UndefObj, print(env, displayString(args[0]), args[1:])
+		}, 1, 2),
+		"displayln": newEnvFunction("displayln", func(env *Env, args ...Expression) (Expression, error) {
+			return UndefObj, print(env, displayString(args[0])+"\n", args[1:])
+		}, 1, 2),
+		"write": newEnvFunction("write", func(env *Env, args ...Expression) (Expression, error) {
+			return UndefObj, print(env, writeString(args[0]), args[1:])
+		}, 1, 2),
+		"newline": newEnvFunction("newline", func(env *Env, args ...Expression) (Expression, error) {
+			return UndefObj, print(env, "\n", args)
+		}, 0, 1),
+		"current-output-port": newEnvFunction("current-output-port", func(env *Env, args ...Expression) (Expression, error) {
+			return current(env), nil
+		}, 0, 0),
+		"pretty-print": newEnvFunction("pretty-print", func(env *Env, args ...Expression) (Expression, error) {
+			width := defaultPrettyWidth
+			if len(args) > 2 {
+				w, ok := args[2].(Integer)
+				if !ok || w <= 0 {
+					return UndefObj, fmt.Errorf("pretty-print: %v is not a valid width", valueToString(args[2]))
+				}
+				width, args = int(w), args[:2]
+			}
+			return UndefObj, print(env, prettyString(args[0], 0, width)+"\n", args[1:])
+		}, 1, 3),
+		"format": newEnvFunction("format", func(env *Env, args ...Expression) (Expression, error) {
+			template, ok := args[1].(String)
+			if !ok {
+				return UndefObj, fmt.Errorf("format: %v is not a string", valueToString(args[1]))
+			}
+			s, err := formatString(string(template), args[2:])
+			if err != nil {
+				return UndefObj, err
+			}
+			switch dest := args[0].(type) {
+			case bool:
+				if !dest {
+					return String(s), nil
+				}
+				return UndefObj, print(env, s, nil)
+			default:
+				return UndefObj, print(env, s, args[:1])
+			}
+		}, 2, -1),
+		"with-output-to-string": newEnvFunction("with-output-to-string", func(env *Env, args ...Expression) (Expression, error) {
+			return withOutputToString(env, args[0])
+		}, 1, 1),
+	}
+}
+
+// isCompound checks whether the value is a non-empty list or vector, which may be wrapped by pretty-print.
+func isCompound(exp Expression) bool {
+	switch v := exp.(type) {
+	case *Pair:
+		return !v.IsNull()
+	case *Vector:
+		return len(v.Elements) > 0
+	default:
+		return false
+	}
+}
+
+// defaultPrettyWidth is the default line width of pretty-print.
+const defaultPrettyWidth = 80
+
+// prettyString returns the representation written by write, the lists and vectors which do not fit in the width
+// are wrapped and the elements are indented under the first one. The consecutive atoms are filled in the same line
+// while they fit, the nested lists and vectors start new lines. The column is the position where the representation
+// starts.
+func prettyString(exp Expression, column, width int) string {
+	flat := writeString(exp)
+	if column+utf8.RuneCountInString(flat) <= width {
+		return flat
+	}
+	var open string
+	var elements []Expression
+	var tail Expression = NilObj
+	switch v := exp.(type) {
+	case *Pair:
+		if v.IsNull() {
+			return flat
+		}
+		open = "("
+		current := Expression(v)
+		for p, ok := current.(*Pair); ok && !p.IsNull(); p, ok = current.(*Pair) {
+			elements = append(elements, p.Car)
+			current = p.Cdr
+		}
+		tail = current
+	case *Vector:
+		if len(v.Elements) == 0 {
+			return flat
+		}
+		open = "#("
+		elements = v.Elements
+	default:
+		return flat
+	}
+	indent := column + len(open)
+	var b strings.Builder
+	b.WriteString(open)
+	current, fill := indent, false
+	for i, e := range elements {
+		s := writeString(e)
+		atom := !isCompound(e)
+		if i > 0 {
+			// the last element is followed by the closing parenthesis
+			end := current + 1 + utf8.RuneCountInString(s)
+			if i == len(elements)-1 && IsNullExp(tail) {
+				end++
+			}
+			if fill && atom && end <= width {
+				b.WriteString(" " + s)
+				current += 1 + utf8.RuneCountInString(s)
+				continue
+			}
+			b.WriteString("\n" + strings.Repeat(" ", indent))
+			current = indent
+		}
+		if !atom {
+			s = prettyString(e, indent, width)
+		}
+		b.WriteString(s)
+		current += utf8.RuneCountInString(s)
+		fill = atom
+	}
+	if !IsNullExp(tail) {
+		b.WriteString("\n" + strings.Repeat(" ", indent) + ". " + prettyString(tail, indent+2, width))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// formatString replaces the directives in the template with the arguments:
+// ~a is the argument written by display, ~s is the argument written by write, ~d is the integer argument in
+// decimal, ~% is the newline and ~~ is the tilde.
+func formatString(template string, args []Expression) (string, error) {
+	var b strings.Builder
+	next := func() (Expression, error) {
+		if len(args) == 0 {
+			return nil, errors.New("format: too few arguments for the directives")
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg, nil
+	}
+	runes := []rune(template)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '~' {
+			b.WriteRune(runes[i])
+			continue
+		}
+		i++
+		if i == len(runes) {
+			return "", errors.New("format: incomplete directive at the end of template")
+		}
+		switch directive := unicode.ToLower(runes[i]); directive {
+		case '%':
+			b.WriteByte('\n')
+		case '~':
+			b.WriteByte('~')
+		case 'a', 's', 'd':
+			arg, err := next()
+			if err != nil {
+				return "", err
+			}
+			switch directive {
+			case 'a':
+				b.WriteString(displayString(arg))
+			case 's':
+				b.WriteString(writeString(arg))
+			default:
+				if !isInteger(arg) {
+					return "", fmt.Errorf("format: ~d requires an integer but got %v", valueToString(arg))
+				}
+				b.WriteString(valueToString(arg))
+			}
+		default:
+			return "", fmt.Errorf("format: unknown directive ~%c", runes[i])
+		}
+	}
+	if len(args) > 0 {
+		return "", errors.New("format: too many arguments for the directives")
+	}
+	return b.String(), nil
+}
+
+// withOutputToString calls the thunk with the output port bound to a string port and returns the output. The port
+// is only bound for the evaluation of the thunk, so it ends when the thunk returns, raises error or escapes by
+// continuation.
+func withOutputToString(env *Env, thunk Expression) (Expression, error) {
+	var b strings.Builder
+	d := env.copyDynamic()
+	d.output = NewOutputPort(&b)
+	if _, err := callProcedure(env.withDynamic(&d), thunk); err != nil {
+		return UndefObj, err
+	}
+	return String(b.String()), nil
+}
@@ -0,0 +1,170 @@
+package goscheme
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOutputProcedures(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(display "a \"string\"")`, `a "string"`},
+		{`(write "a \"string\"")`, `"a \"string\""`},
+		{`(write "back\\slash")`, `"back\\slash"`},
+		{`(write "a\\n")`, `"a\\n"`},
+		{`(display "a\nb")`, "a\nb"},
+		{`(display #\a)`, `a`},
+		{`(write #\a)`, `#\a`},
+		{`(write #\space)`, `#\space`},
+		{`(display '(1 "two" #\3 #f (4.5)))`, `(1 two 3 #f (4.5))`},
+		{`(write '(1 "two" #\3 #f (4.5)))`, `(1 "two" #\3 #f (4.5))`},
+		{`(display (cons "a" "b"))`, `(a . b)`},
+		{`(write (cons "a" "b"))`, `("a" . "b")`},
+		{`(display (vector "a" #\b))`, `#(a b)`},
+		{`(write (vector "a" #\b))`, `#("a" #\b)`},
+		{`(write 'sym) (newline) (display 1.5)`, "sym\n1.5"},
+		{`(displayln "line")`, "line\n"},
+	}
+	for _, c := range testCases {
+		env := NewBuiltinEnv()
+		var buf bytes.Buffer
+		env.SetOutput(&buf)
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, buf.String(), c.input)
+	}
+}
+
+func TestEnvOutput(t *testing.T) {
+	env := NewBuiltinEnv()
+	var buf bytes.Buffer
+	env.SetOutput(&buf)
+	// the output is shared by the child environments and procedures
+	_, err := EvalAll(strToToken(`(define (greet name) (display "hello ") (display name)) (greet "world")`),
+		NewChildEnv(env))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", buf.String())
+	assert.Equal(t, &buf, env.Output())
+}
+
+func TestWithOutputToString(t *testing.T) {
+	env := NewBuiltinEnv()
+	var buf bytes.Buffer
+	env.SetOutput(&buf)
+	ret, err := EvalAll(strToToken(`
+		(display "before ")
+		(define s (with-output-to-string (lambda () (display "x = ") (write "1") (newline))))
+		(display "after")
+		s`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, String("x = \"1\"\n"), ret)
+	assert.Equal(t, "before after", buf.String())
+
+	// nested capture
+	ret, err = EvalAll(strToToken(`
+		(with-output-to-string
+		  (lambda () (display "a") (display (string-length (with-output-to-string (lambda () (display "bcd")))))))`),
+		env)
+	assert.Nil(t, err)
+	assert.Equal(t, String("a3"), ret)
+
+	// the output port is restored after error and escape
+	buf.Reset()
+	_, err = EvalAll(strToToken(`(with-output-to-string (lambda () (display "lost") (car '())))`), env)
+	assert.NotNil(t, err)
+	_, err = EvalAll(strToToken(`(call/cc (lambda (k) (with-output-to-string (lambda () (display "lost") (k 1)))))`),
+		env)
+	assert.Nil(t, err)
+	_, err = EvalAll(strToToken(`(display "restored")`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "restored", buf.String())
+
+	_, err = EvalAll(strToToken(`(with-output-to-string 1)`), env)
+	assert.NotNil(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(format #f "~a + ~a = ~a~%" 1 2 3)`, String("1 + 2 = 3\n")},
+		{`(format #f "~a and ~s" "str" "str")`, String(`str and "str"`)},
+		{`(format #f "~a ~s" #\a #\a)`, String(`a #\a`)},
+		{`(format #f "~A ~S" '(1 "x") '(1 "x"))`, String(`(1 x) (1 "x")`)},
+		{`(format #f "~d items" 42)`, String("42 items")},
+		{`(format #f "100~~")`, String("100~")},
+		{`(format #f "no directives")`, String("no directives")},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	env := NewBuiltinEnv()
+	var buf bytes.Buffer
+	env.SetOutput(&buf)
+	ret, err := EvalAll(strToToken(`(format #t "~a~%" "to output")
+		(define p (open-output-string)) (format p "~s" "to port") (get-output-string p)`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, String(`"to port"`), ret)
+	assert.Equal(t, "to output\n", buf.String())
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(format #f "~a ~a" 1)`, "format: too few arguments for the directives"},
+		{`(format #f "~a" 1 2)`, "format: too many arguments for the directives"},
+		{`(format #f "~d" 1.5)`, "format: ~d requires an integer but got 1.5"},
+		{`(format #f "~x" 1)`, "format: unknown directive ~x"},
+		{`(format #f "~")`, "format: incomplete directive at the end of template"},
+		{`(format #f 1)`, "format: 1 is not a string"},
+		{`(format 1 "a")`, "1 is not a port"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestWriteUnspecified(t *testing.T) {
+	env := NewBuiltinEnv()
+	out := &bytes.Buffer{}
+	env.SetOutput(out)
+	_, err := EvalAll(strToToken(`(write (if #f #f)) (display (vector-fill! (make-vector 1) 0))`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "#<void>#<void>", out.String())
+}
+
+func TestPrettyPrint(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(pretty-print '(1 2 3))`, "(1 2 3)\n"},
+		{`(pretty-print "str")`, "\"str\"\n"},
+		{`(pretty-print '(define (f x) (if (> x 0) (* x 2) (- x))) (current-output-port) 20)`,
+			"(define\n (f x)\n (if\n  (> x 0)\n  (* x 2)\n  (- x)))\n"},
+		{`(pretty-print (vector 1 (list 2 3) "four") (current-output-port) 10)`,
+			"#(1\n  (2 3)\n  \"four\")\n"},
+		{`(pretty-print (cons* 'aaaa 'bbbb 'cccc) (current-output-port) 10)`, "(aaaa bbbb\n . cccc)\n"},
+		{`(pretty-print (iota 30))`,
+			"(0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28\n 29)\n"},
+	}
+	for _, c := range testCases {
+		env := NewBuiltinEnv()
+		out := &bytes.Buffer{}
+		env.SetOutput(out)
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, out.String(), c.input)
+	}
+
+	_, err := EvalAll(strToToken(`(pretty-print 1 (current-output-port) 0)`), NewBuiltinEnv())
+	assert.EqualError(t, err, "pretty-print: 0 is not a valid width")
+}
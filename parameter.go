@@ -0,0 +1,124 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Parameter is the dynamically scoped value created by make-parameter.
+// Calling the parameter without arguments returns the current value, parameterize binds new values to the
+// parameters while evaluating its body.
+type Parameter struct {
+	// the value outside parameterize
+	value Expression
+	// the procedure converting the values bound to the parameter, nil if not provided
+	converter Expression
+}
+
+func (p *Parameter) String() string {
+	return "#<parameter>"
+}
+
+// Value returns the value of the parameter outside parameterize, the values bound by parameterize are only visible
+// to the evaluation of its body.
+func (p *Parameter) Value() Expression {
+	return p.value
+}
+
+// valueIn returns the current value of the parameter in the evaluation in env.
+func (p *Parameter) valueIn(env *Env) Expression {
+	if d := env.dynamicState(); d != nil {
+		for b := d.parameters; b != nil; b = b.next {
+			if b.parameter == p {
+				return b.value
+			}
+		}
+	}
+	return p.value
+}
+
+// parameterBinding is the value bound to the parameter by parameterize, the bindings form a list from the
+// innermost parameterize to the outermost.
+type parameterBinding struct {
+	parameter *Parameter
+	value     Expression
+	next      *parameterBinding
+}
+
+// convert applies the converter of the parameter to the value.
+func (p *Parameter) convert(env *Env, value Expression) (Expression, error) {
+	if p.converter == nil {
+		return value, nil
+	}
+	return callProcedure(env, p.converter, value)
+}
+
+// makeParameterFunc creates the parameter with the initial value and an optional converter.
+// (make-parameter value [converter])
+func makeParameterFunc(env *Env, args ...Expression) (Expression, error) {
+	p := &Parameter{}
+	if len(args) > 1 {
+		p.converter = args[1]
+	}
+	value, err := p.convert(env, args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	p.value = value
+	return p, nil
+}
+
+// evalParameterize evaluates the body with the values bound to the parameters. The bindings are only visible to
+// the evaluation of the body, so they end when the body returns, raises an error or escapes by a continuation, and
+// the other evaluations do not see them.
+// (parameterize ((param value) ...) body ...)
+func evalParameterize(args []Expression, env *Env) (Expression, error) {
+	if len(args) < 2 {
+		return UndefObj, errors.New("parameterize: syntax error (parameterize should pass the bindings and body)")
+	}
+	bindings, ok := args[0].([]Expression)
+	if !ok {
+		return UndefObj, errors.New("parameterize: syntax error (not a valid binding list)")
+	}
+	params := make([]*Parameter, len(bindings))
+	values := make([]Expression, len(bindings))
+	for i, b := range bindings {
+		binding, ok := b.([]Expression)
+		if !ok || len(binding) != 2 {
+			return UndefObj, errors.New("parameterize: syntax error (not a valid binding)")
+		}
+		v, err := Eval(binding[0], env)
+		if err != nil {
+			return UndefObj, err
+		}
+		if params[i], ok = v.(*Parameter); !ok {
+			return UndefObj, fmt.Errorf("parameterize: %v is not a parameter", valueToString(v))
+		}
+		if values[i], err = Eval(binding[1], env); err != nil {
+			return UndefObj, err
+		}
+		if values[i], err = params[i].convert(env, values[i]); err != nil {
+			return UndefObj, err
+		}
+	}
+	d := env.copyDynamic()
+	for i, p := range params {
+		d.parameters = &parameterBinding{parameter: p, value: values[i], next: d.parameters}
+	}
+	return Eval(sequenceToExp(args[1:]), env.withDynamic(&d))
+}
+
+// dynamicWindFunc calls the thunk between the before and after thunks, the after thunk is called even if the thunk
+// raises an error or escapes by a continuation.
+// (dynamic-wind before thunk after)
+func dynamicWindFunc(env *Env, args ...Expression) (ret Expression, err error) {
+	if _, err := callProcedure(env, args[0]); err != nil {
+		return UndefObj, err
+	}
+	defer func() {
+		if _, afterErr := callProcedure(env, args[2]); afterErr != nil && err == nil {
+			ret, err = UndefObj, afterErr
+		}
+	}()
+	return callProcedure(env, args[1])
+}
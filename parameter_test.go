@@ -0,0 +1,70 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestParameter(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(define p (make-parameter 10)) (list (p) (parameterize ((p 20)) (p)) (p))`, "(10 20 10)"},
+		{`(define p (make-parameter 10 (lambda (x) (* x 2)))) (list (p) (parameterize ((p 3)) (p)) (p))`, "(20 6 20)"},
+		{`(define p (make-parameter 1)) (define (get) (p)) (parameterize ((p 2)) (parameterize ((p 3)) (get)))`, "3"},
+		{`(define p (make-parameter 1)) (define q (make-parameter 2)) (parameterize ((p (q)) (q (p))) (list (p) (q)))`, "(2 1)"},
+		{`(define p (make-parameter 1)) (parameterize ((p 2)) (map (lambda (x) (+ x (p))) '(1 2)))`, "(3 4)"},
+		{`(define p (make-parameter 1)) (define f (parameterize ((p 2)) (lambda () (p)))) (f)`, "1"},
+		// the values are restored on the non-local exits
+		{`(define p (make-parameter 1)) (call/cc (lambda (k) (parameterize ((p 2)) (k 0)))) (p)`, "1"},
+		{`(define p (make-parameter 1)) (guard (e (#t 0)) (parameterize ((p 2)) (error "boom"))) (p)`, "1"},
+		{`(define trace '())
+		  (define (note x) (set! trace (cons x trace)))
+		  (call/cc (lambda (k) (dynamic-wind (lambda () (note 'before)) (lambda () (k 'escaped) (note 'never)) (lambda () (note 'after)))))
+		  (reverse trace)`, "(before after)"},
+		{`(dynamic-wind (lambda () 1) (lambda () 2) (lambda () 3))`, "2"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(parameterize ((car 1)) 1)`, "parameterize: #[BuiltinFunction] is not a parameter"},
+		{`(define p (make-parameter 1)) (p 2)`, "parameter: expected 0 arguments"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestParameterConcurrency(t *testing.T) {
+	global := NewBuiltinEnv()
+	_, err := EvalAll(strToToken(`(define p (make-parameter 0))
+		(define (check n k) (cond ((= k 0) #t) ((= (p) n) (check n (- k 1))) (else #f)))`), global)
+	assert.Nil(t, err)
+
+	// the values bound by parameterize are invisible to the other evaluations
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := NewChildEnv(global)
+			env.Set("n", Integer(i))
+			ret, err := EvalAll(strToToken(`(parameterize ((p n)) (check n 1000))`), env)
+			assert.Nil(t, err)
+			assert.Equal(t, true, ret)
+		}(i)
+	}
+	wg.Wait()
+	ret, _ := EvalAll(strToToken(`(p)`), global)
+	assert.Equal(t, Integer(0), ret)
+}
@@ -3,7 +3,20 @@ package goscheme
 import "fmt"
 
 // Parse read and parse the tokens to construct a syntax tree represents in nested slices.
-func Parse(tokens *[]string) (ret []Expression, err error) {
+func Parse(tokens *[]string) ([]Expression, error) {
+	return parseTokens(tokens, nil, nil)
+}
+
+// ParseWithPositions parses the tokens like Parse, positions are the source positions of tokens returned by
+// Tokenizer.TokensWithPositions. The returned table records the positions where the parsed lists start, which
+// locates the SchemeError raised when evaluating them, see Positions.Locate.
+func ParseWithPositions(tokens *[]string, positions []Position) ([]Expression, Positions, error) {
+	table := make(Positions)
+	ret, err := parseTokens(tokens, positions, table)
+	return ret, table, err
+}
+
+func parseTokens(tokens *[]string, positions []Position, table Positions) (ret []Expression, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("%s", r)
@@ -11,39 +24,93 @@ func Parse(tokens *[]string) (ret []Expression, err error) {
 	}()
 
 	for len(*tokens) > 0 {
-		ret = append(ret, readTokens(tokens))
+		ret = append(ret, readTokens(tokens, &positions, table))
+	}
+	return
+}
+
+// quoteAbbreviations maps the prefix tokens to the syntax they abbreviate.
+var quoteAbbreviations = map[string]string{
+	"'":  "quote",
+	"`":  "quasiquote",
+	",":  "unquote",
+	",@": "unquote-splicing",
+}
+
+// nextToken removes the first token and its position, the position is zero if unknown.
+func nextToken(tokens *[]string, positions *[]Position) (token string, pos Position) {
+	token = (*tokens)[0]
+	*tokens = (*tokens)[1:]
+	if len(*positions) > 0 {
+		pos = (*positions)[0]
+		*positions = (*positions)[1:]
 	}
 	return
 }
 
-func readTokens(tokens *[]string) Expression {
+func readTokens(tokens *[]string, positions *[]Position, table Positions) Expression {
 	if len(*tokens) == 0 {
 		return nil
 	}
-	token := (*tokens)[0]
-	*tokens = (*tokens)[1:]
+	token, pos := nextToken(tokens, positions)
 
 	switch token {
 	case "(":
-		ret := make([]Expression, 0)
-		for len(*tokens) >= 0 && (*tokens)[0] != ")" {
-			nextPart := readTokens(tokens)
-			ret = append(ret, nextPart)
-		}
-		if len(*tokens) == 0 {
-			panic("syntax error: missing ')'")
-		}
-		*tokens = (*tokens)[1:]
+		ret := readList(tokens, positions, table)
+		table.set(ret, pos)
 		return ret
+	case "#(":
+		elements := readList(tokens, positions, table)
+		for _, e := range elements {
+			if e == "." {
+				panic("syntax error: unexpected '.' in vector")
+			}
+		}
+		return vectorLiteral(elements)
 	case ")":
 		panic("syntax error: unexpected ')'")
-	case "'":
+	case ".":
+		panic("syntax error: unexpected '.'")
+	case "'", "`", ",", ",@":
 		ret := make([]Expression, 0, 4)
-		ret = append(ret, "quote")
-		nextPart := readTokens(tokens)
+		ret = append(ret, quoteAbbreviations[token])
+		nextPart := readTokens(tokens, positions, table)
 		ret = append(ret, nextPart)
+		table.set(ret, pos)
 		return ret
 	default:
+		if hasNumberPrefix(token) && !IsNumber(token) {
+			panic(fmt.Sprintf("syntax error: invalid number %s", token))
+		}
 		return token
 	}
 }
+
+// readList reads the elements until the matching ')'. The dotted list (a b . c) keeps the "." before its last
+// element, which must be the only datum after the dot.
+func readList(tokens *[]string, positions *[]Position, table Positions) []Expression {
+	ret := make([]Expression, 0)
+	for len(*tokens) > 0 && (*tokens)[0] != ")" {
+		if (*tokens)[0] == "." {
+			nextToken(tokens, positions)
+			if len(ret) == 0 {
+				panic("syntax error: unexpected '.'")
+			}
+			if len(*tokens) == 0 || (*tokens)[0] == ")" {
+				panic("syntax error: missing datum after '.'")
+			}
+			ret = append(ret, ".", readTokens(tokens, positions, table))
+			if len(*tokens) > 0 && (*tokens)[0] != ")" {
+				panic("syntax error: more than one datum after '.'")
+			}
+			continue
+		}
+		nextPart := readTokens(tokens, positions, table)
+		ret = append(ret, nextPart)
+	}
+	if len(*tokens) == 0 {
+		panic("syntax error: missing ')'")
+	}
+	nextToken(tokens, positions)
+	return ret
+}
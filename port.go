@@ -0,0 +1,295 @@
+package goscheme
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EOFObject represents the end of file returned by the input procedures.
+type EOFObject struct{}
+
+// String returns the string representing the eof object.
+func (EOFObject) String() string {
+	return "#<eof>"
+}
+
+// EOFObj is the common object of EOFObject.
+var EOFObj = EOFObject{}
+
+// Port represents the input port the procedures like read consume or the output port the procedures like display
+// write to. Should only use with pointer.
+type Port struct {
+	// reader is nil for the output port
+	reader *bufio.Reader
+	// writer is nil for the input port
+	writer io.Writer
+	// closer closes the underlying file, nil if there is nothing to close
+	closer io.Closer
+	closed bool
+}
+
+// NewInputPort returns the input port reading from r.
+func NewInputPort(r io.Reader) *Port {
+	return &Port{reader: bufio.NewReader(r)}
+}
+
+// NewOutputPort returns the output port writing to w.
+func NewOutputPort(w io.Writer) *Port {
+	return &Port{writer: w}
+}
+
+// String returns the string representing the port.
+func (p *Port) String() string {
+	if p.reader != nil {
+		return "#<input-port>"
+	}
+	return "#<output-port>"
+}
+
+// SetInput sets the reader the input procedures like read consume by default, it is shared by the environments
+// extended from the same global environment. The default input is os.Stdin.
+func (e *Env) SetInput(r io.Reader) {
+	if e.state == nil {
+		return
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	e.state.input = NewInputPort(r)
+}
+
+// InputPort returns the input port the input procedures consume by default.
+func (e *Env) InputPort() *Port {
+	if e.state == nil {
+		return stdinPort
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	if e.state.input == nil {
+		return stdinPort
+	}
+	return e.state.input
+}
+
+// stdinPort is the input port reading os.Stdin, it is shared so the buffered input is not lost.
+var stdinPort = NewInputPort(os.Stdin)
+
+// readDatum reads the tokens of the next datum from the port and returns the datum, the eof object is returned if
+// there is no more datum.
+func (p *Port) readDatum() (Expression, error) {
+	t := &Tokenizer{Source: p.reader, currentCh: -1, line: 1}
+	var tokens []string
+	depth := 0
+	for {
+		token, ok := t.NextToken()
+		if !ok {
+			if len(tokens) == 0 {
+				return EOFObj, nil
+			}
+			return UndefObj, errors.New("read: unexpected end of input")
+		}
+		tokens = append(tokens, token)
+		switch token {
+		case "(", "#(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth < 0 {
+			return UndefObj, errors.New("read: unexpected ')'")
+		}
+		if _, isPrefix := quoteAbbreviations[token]; depth == 0 && !isPrefix {
+			break
+		}
+	}
+	// the tokenizer reads one character ahead, which belongs to the remaining input
+	if !t.EOF {
+		p.reader.UnreadRune()
+	}
+	exps, err := Parse(&tokens)
+	if err != nil {
+		return UndefObj, fmt.Errorf("read: %v", err)
+	}
+	return evalQuote(exps, nil)
+}
+
+func expressionToPort(exp Expression) (*Port, error) {
+	p, ok := exp.(*Port)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a port", valueToString(exp))
+	}
+	return p, nil
+}
+
+func expressionToInputPort(exp Expression) (*Port, error) {
+	p, err := expressionToPort(exp)
+	if err != nil {
+		return nil, err
+	}
+	if p.reader == nil {
+		return nil, fmt.Errorf("%v is not an input port", valueToString(exp))
+	}
+	return p, nil
+}
+
+func expressionToOutputPort(exp Expression) (*Port, error) {
+	p, err := expressionToPort(exp)
+	if err != nil {
+		return nil, err
+	}
+	if p.writer == nil {
+		return nil, fmt.Errorf("%v is not an output port", valueToString(exp))
+	}
+	return p, nil
+}
+
+func isPortFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(*Port)
+	return ok, nil
+}
+
+func isInputPortFunc(args ...Expression) (Expression, error) {
+	p, ok := args[0].(*Port)
+	return ok && p.reader != nil, nil
+}
+
+func isOutputPortFunc(args ...Expression) (Expression, error) {
+	p, ok := args[0].(*Port)
+	return ok && p.writer != nil, nil
+}
+
+func openInputStringFunc(args ...Expression) (Expression, error) {
+	s, ok := args[0].(String)
+	if !ok {
+		return UndefObj, fmt.Errorf("open-input-string: %v is not a string", valueToString(args[0]))
+	}
+	return NewInputPort(strings.NewReader(string(s))), nil
+}
+
+// openOutputStringFunc returns the output port accumulating the output, which is retrieved by get-output-string.
+func openOutputStringFunc(_ ...Expression) (Expression, error) {
+	return NewOutputPort(new(strings.Builder)), nil
+}
+
+func getOutputStringFunc(args ...Expression) (Expression, error) {
+	p, err := expressionToOutputPort(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	b, ok := p.writer.(*strings.Builder)
+	if !ok {
+		return UndefObj, fmt.Errorf("get-output-string: %v is not a string port", valueToString(args[0]))
+	}
+	return String(b.String()), nil
+}
+
+// peekChar returns the next character without consuming it, the eof object is returned at the end.
+func (p *Port) peekChar() (Expression, error) {
+	ch, err := p.readChar()
+	if _, ok := ch.(Char); ok {
+		p.reader.UnreadRune()
+	}
+	return ch, err
+}
+
+// readLine reads the next line without the line ending, the eof object is returned at the end.
+func (p *Port) readLine() (Expression, error) {
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return EOFObj, nil
+		}
+	} else if err != nil {
+		return UndefObj, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return String(strings.TrimSuffix(line, "\r")), nil
+}
+
+// Close closes the port and the underlying file if any. It is no-op to close the closed port.
+func (p *Port) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// openInputFileFunc opens the file as an input port, the port should be closed by close-port.
+func openInputFileFunc(args ...Expression) (Expression, error) {
+	name, ok := args[0].(String)
+	if !ok {
+		return UndefObj, fmt.Errorf("open-input-file: %v is not a string", valueToString(args[0]))
+	}
+	f, err := os.Open(string(name))
+	if err != nil {
+		return UndefObj, fmt.Errorf("open-input-file: %v", err)
+	}
+	p := NewInputPort(f)
+	p.closer = f
+	return p, nil
+}
+
+func closePortFunc(args ...Expression) (Expression, error) {
+	p, err := expressionToPort(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return UndefObj, p.Close()
+}
+
+// readChar reads the next character from the port, the eof object is returned at the end.
+func (p *Port) readChar() (Expression, error) {
+	r, _, err := p.reader.ReadRune()
+	if err == io.EOF {
+		return EOFObj, nil
+	}
+	if err != nil {
+		return UndefObj, err
+	}
+	return Char(r), nil
+}
+
+func eofObjectFunc(_ ...Expression) (Expression, error) {
+	return EOFObj, nil
+}
+
+func isEOFObjectFunc(args ...Expression) (Expression, error) {
+	_, ok := args[0].(EOFObject)
+	return ok, nil
+}
+
+// inputFunctions returns the input procedures reading from the input port of env by default.
+func inputFunctions(env *Env) map[Symbol]Function {
+	// input wraps the method reading the port in the optional argument or the default input port
+	input := func(name string, read func(p *Port) (Expression, error)) Function {
+		return NewFunction(name, func(args ...Expression) (Expression, error) {
+			p := env.InputPort()
+			if len(args) > 0 {
+				var err error
+				if p, err = expressionToInputPort(args[0]); err != nil {
+					return UndefObj, err
+				}
+			}
+			if p.closed {
+				return UndefObj, fmt.Errorf("%s: %v is closed", name, valueToString(p))
+			}
+			return read(p)
+		}, 0, 1)
+	}
+	return map[Symbol]Function{
+		"read":      input("read", (*Port).readDatum),
+		"read-char": input("read-char", (*Port).readChar),
+		"peek-char": input("peek-char", (*Port).peekChar),
+		"read-line": input("read-line", (*Port).readLine),
+		"current-input-port": NewFunction("current-input-port", func(args ...Expression) (Expression, error) {
+			return env.InputPort(), nil
+		}, 0, 0),
+	}
+}
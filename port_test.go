@@ -0,0 +1,143 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	env := NewBuiltinEnv()
+	env.SetInput(strings.NewReader(`(1 (2 "three")) sym 4.5 "str" #\a
+		#(1 2) 'quoted ; comment
+		(a)(b)`))
+	expected := []string{`(1 (2 "three"))`, `sym`, `4.5`, `"str"`, `#\a`, `#(1 2)`, `(quote quoted)`, `(a)`, `(b)`}
+	for _, e := range expected {
+		ret, err := EvalAll(strToToken(`(read)`), env)
+		assert.Nil(t, err)
+		assert.Equal(t, e, valueToString(ret))
+	}
+	for i := 0; i < 2; i++ {
+		ret, err := EvalAll(strToToken(`(read (current-input-port))`), env)
+		assert.Nil(t, err)
+		assert.Equal(t, EOFObj, ret)
+	}
+
+	// the datum is not evaluated
+	env.SetInput(strings.NewReader(`(+ 1 2)`))
+	ret, err := EvalAll(strToToken(`(define exp (read)) (list (car exp) (eval exp))`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "(+ 3)", valueToString(ret))
+
+	env.SetInput(strings.NewReader(`(1 2`))
+	_, err = EvalAll(strToToken(`(read)`), env)
+	assert.EqualError(t, err, "read: unexpected end of input")
+
+	env.SetInput(strings.NewReader(`)`))
+	_, err = EvalAll(strToToken(`(read)`), env)
+	assert.EqualError(t, err, "read: unexpected ')'")
+
+	_, err = EvalAll(strToToken(`(read 1)`), env)
+	assert.EqualError(t, err, "1 is not a port")
+}
+
+func TestEOFObject(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(eof-object)`, EOFObj},
+		{`(eof-object? (eof-object))`, true},
+		{`(eof-object? '())`, false},
+		{`(eqv? (eof-object) (eof-object))`, true},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+}
+
+func TestStringPorts(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(define p (open-input-string "(1 2) x")) (list (read p) (read p) (eof-object? (read p)))`,
+			&Pair{&Pair{Integer(1), &Pair{Integer(2), NilObj}}, &Pair{Quote("x"), &Pair{true, NilObj}}}},
+		{`(define p (open-input-string "ab")) (list (read-char p) (read-char p) (eof-object? (read-char p)))`,
+			&Pair{Char('a'), &Pair{Char('b'), &Pair{true, NilObj}}}},
+		{`(define p (open-input-string "1 23")) (read p) (list (read-char p) (read p))`,
+			&Pair{Char(' '), &Pair{Integer(23), NilObj}}},
+		{`(define p (open-output-string))
+		  (write "a" p) (display #\b p) (newline p) (display '(1 "c") p)
+		  (get-output-string p)`, String("\"a\"b\n(1 c)")},
+		{`(get-output-string (open-output-string))`, String("")},
+		{`(list (port? (open-input-string "")) (input-port? (open-input-string ""))
+		        (output-port? (open-input-string "")) (output-port? (open-output-string)) (port? 1))`,
+			&Pair{true, &Pair{true, &Pair{false, &Pair{true, &Pair{false, NilObj}}}}}},
+		{`(output-port? (current-output-port))`, true},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(open-input-string 1)`, "open-input-string: 1 is not a string"},
+		{`(read (open-output-string))`, "#<output-port> is not an input port"},
+		{`(display 1 (open-input-string ""))`, "#<input-port> is not an output port"},
+		{`(get-output-string (current-output-port))`, "get-output-string: #<output-port> is not a string port"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), NewBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestFilePorts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("first line\r\nsecond (1 2)\n\nlast"), 0o644))
+
+	env := NewBuiltinEnv()
+	env.Set("path", String(path))
+	ret, err := EvalAll(strToToken(`
+		(define p (open-input-file path))
+		(define (read-lines p acc)
+		  (let ((line (read-line p)))
+		    (if (eof-object? line) (reverse acc) (read-lines p (cons line acc)))))
+		(define lines (read-lines p '()))
+		(close-port p)
+		lines`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, `("first line" "second (1 2)" "" "last")`, valueToString(ret))
+
+	ret, err = EvalAll(strToToken(`
+		(define p (open-input-file path))
+		(define result (list (peek-char p) (peek-char p) (read-char p) (read-char p) (read-line p)
+		                     (read-char p) (read-char p) (read p) (read p)))
+		(close-port p)
+		result`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, `(#\f #\f #\f #\i "rst line" #\s #\e cond (1 2))`, valueToString(ret))
+
+	_, err = EvalAll(strToToken(`(define p (open-input-file path)) (close-port p) (close-port p) (read-line p)`), env)
+	assert.EqualError(t, err, "read-line: #<input-port> is closed")
+
+	_, err = EvalAll(strToToken(`(define p (open-output-string)) (close-port p) (display 1 p)`), env)
+	assert.EqualError(t, err, "#<output-port> is closed")
+
+	_, err = EvalAll(strToToken(`(open-input-file "not-exists.txt")`), env)
+	assert.EqualError(t, err, "open-input-file: open not-exists.txt: no such file or directory")
+
+	sandbox := NewSandboxEnv()
+	sandbox.Set("path", String(path))
+	_, err = EvalAll(strToToken(`(open-input-file path)`), sandbox)
+	assert.EqualError(t, err, "open-input-file: not allowed in sandbox")
+}
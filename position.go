@@ -0,0 +1,51 @@
+package goscheme
+
+import "fmt"
+
+// Position represents the position in source, both line and column start from 1.
+type Position struct {
+	Line, Column int
+}
+
+// String returns the string representing the position.
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Positions maps the lists parsed by ParseWithPositions to the source positions where they start. The lists are
+// identified by the address of their first element.
+type Positions map[*Expression]Position
+
+// set records the source position of the parsed list, the blank list and zero position are ignored.
+func (p Positions) set(list []Expression, pos Position) {
+	if p == nil || len(list) == 0 || pos.Line == 0 {
+		return
+	}
+	p[&list[0]] = pos
+}
+
+// Of returns the source position of the parsed list.
+func (p Positions) Of(exp Expression) (Position, bool) {
+	list, ok := exp.([]Expression)
+	if !ok || len(list) == 0 {
+		return Position{}, false
+	}
+	pos, ok := p[&list[0]]
+	return pos, ok
+}
+
+// Locate sets the source position of the *SchemeError to the position of the innermost expression recorded in the
+// error whose position is known. The other errors are returned unchanged.
+func (p Positions) Locate(err error) error {
+	se, ok := err.(*SchemeError)
+	if !ok || se.Line > 0 {
+		return err
+	}
+	for _, exp := range append([]Expression{se.Expression}, se.Stack...) {
+		if pos, ok := p.Of(exp); ok {
+			se.Line, se.Column = pos.Line, pos.Column
+			break
+		}
+	}
+	return se
+}
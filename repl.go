@@ -68,14 +68,15 @@ func (i *Interpreter) Run() (err error) {
 		i.runInInteractiveMode()
 		return nil
 	}
-	i.runNormal()
-	return nil
+	return i.runNormal()
 }
 
 func (i *Interpreter) runNormal() error {
 	go i.checkExit()
 	i.check()
 	scanner := bufio.NewScanner(i.input)
+	// the line number of the first line in current fragment
+	line, fragmentLine := 0, 1
 	for {
 		if eof := !scanner.Scan(); eof {
 			if i.indents() != 0 {
@@ -84,20 +85,27 @@ func (i *Interpreter) runNormal() error {
 			return nil
 		}
 		b := scanner.Bytes()
+		line++
+		if len(i.currentFragment) == 0 {
+			fragmentLine = line
+		}
 		i.currentLineScript = b
 		i.currentFragment = append(i.currentFragment, '\n')
 		i.currentFragment = append(i.currentFragment, i.currentLineScript...)
 		if i.indents() == 0 {
 			tokenizer := NewTokenizerFromReader(bytes.NewReader(i.currentFragment))
-			tokens := tokenizer.Tokens()
-			expTokens, err := Parse(&tokens)
+			tokens, positions := tokenizer.TokensWithPositions()
+			// the fragment starts with a blank line
+			for k := range positions {
+				positions[k].Line += fragmentLine - 2
+			}
+			expTokens, table, err := ParseWithPositions(&tokens, positions)
 			if err != nil {
-				fmt.Printf("%s\n", err)
 				return err
 			}
 			_, err = EvalAll(expTokens, i.env)
 			if err != nil {
-				return err
+				return table.Locate(err)
 			}
 			i.currentFragment = make([]byte, 0, 10)
 		}
@@ -222,15 +230,15 @@ func (i *Interpreter) evalPromptInput(input string) {
 	i.currentFragment = append(i.currentFragment, i.currentLineScript...)
 	if i.indents() <= 0 {
 		tokenizer := NewTokenizerFromReader(bytes.NewReader(i.currentFragment))
-		tokens := tokenizer.Tokens()
-		expTokens, err := Parse(&tokens)
+		tokens, positions := tokenizer.TokensWithPositions()
+		expTokens, table, err := ParseWithPositions(&tokens, positions)
 		if err != nil {
 			i.print(fmt.Sprintf("%s\n", err), prompt.Red)
 			return
 		}
 		ret, err := EvalAll(expTokens, i.env)
 		if err != nil {
-			i.print(fmt.Sprintf("err:=>%s\n", err), prompt.Red)
+			i.print(fmt.Sprintf("err:=>%s\n", table.Locate(err)), prompt.Red)
 		}
 		if shouldPrint(ret) && err == nil {
 			i.print(fmt.Sprintf("#=>%s\n", valueToString(ret)), prompt.Green)
@@ -256,3 +264,138 @@ func NewREPLInterpreter() *Interpreter {
 	i.initPromote()
 	return i
 }
+
+const (
+	replPrompt             = ">>> "
+	replContinuationPrompt = "... "
+)
+
+// REPL reads the expressions from the input, evaluates them and writes the results to the output.
+// An incomplete expression spanning multiple lines is read with the continuation prompt until it is balanced.
+type REPL struct {
+	in  *bufio.Reader
+	out io.Writer
+	env *Env
+}
+
+// NewREPL constructs a *REPL reading from in and writing the prompts, results and errors to out, the output of the
+// evaluated expressions is also written to out.
+func NewREPL(in io.Reader, out io.Writer) *REPL {
+	env := NewBuiltinEnv()
+	env.SetOutput(out)
+	return &REPL{in: bufio.NewReader(in), out: out, env: env}
+}
+
+// Run reads and evaluates the input until the end of input or (exit) is evaluated. The errors of evaluation are
+// written to the output and the REPL continues with the next expression.
+func (r *REPL) Run() error {
+	var fragment strings.Builder
+	for {
+		if fragment.Len() == 0 {
+			fmt.Fprint(r.out, replPrompt)
+		} else {
+			fmt.Fprint(r.out, replContinuationPrompt)
+		}
+		line, err := r.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		fragment.WriteString(line)
+		depth, inString := balance(fragment.String())
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			if depth > 0 || inString {
+				fmt.Fprintln(r.out, "err:=>syntax error: unexpected end of input")
+				return nil
+			}
+		} else if depth > 0 || inString {
+			continue
+		}
+		source := fragment.String()
+		fragment.Reset()
+		if depth < 0 {
+			fmt.Fprintln(r.out, "err:=>syntax error: missing (")
+		} else if r.eval(source) {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// eval evaluates the expressions of the source one by one and writes their results, it returns true if (exit) is
+// evaluated.
+func (r *REPL) eval(source string) (exited bool) {
+	tokens := Tokenize(source)
+	exps, err := Parse(&tokens)
+	if err != nil {
+		fmt.Fprintf(r.out, "err:=>%s\n", err)
+		return false
+	}
+	for _, exp := range exps {
+		ret, err := EvalAll([]Expression{exp}, r.env)
+		select {
+		case <-exit:
+			return true
+		default:
+		}
+		if err != nil {
+			fmt.Fprintf(r.out, "err:=>%s\n", err)
+			return false
+		}
+		if shouldPrint(ret) {
+			fmt.Fprintf(r.out, "#=>%s\n", writeString(ret))
+		}
+	}
+	return false
+}
+
+// balance returns the number of the unclosed parentheses of the source and whether the source ends inside a string
+// literal. The parentheses in the strings, comments and character literals are ignored, the unclosed block comment
+// counts as an unclosed parenthesis.
+func balance(source string) (depth int, inString bool) {
+	runes := []rune(source)
+	for k := 0; k < len(runes); k++ {
+		switch r := runes[k]; {
+		case inString:
+			if r == '\\' {
+				k++
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case r == ';':
+			for k < len(runes) && runes[k] != '\n' {
+				k++
+			}
+		case r == '#' && k+1 < len(runes) && runes[k+1] == '\\':
+			k += 2
+		case r == '#' && k+1 < len(runes) && runes[k+1] == ';':
+			// the datum comment comments out the next datum rather than the rest of line
+			k++
+		case r == '#' && k+1 < len(runes) && runes[k+1] == '|':
+			comments := 0
+			for ; k+1 < len(runes); k++ {
+				if runes[k] == '#' && runes[k+1] == '|' {
+					comments, k = comments+1, k+1
+				} else if runes[k] == '|' && runes[k+1] == '#' {
+					comments, k = comments-1, k+1
+				}
+				if comments == 0 {
+					break
+				}
+			}
+			if comments > 0 {
+				// the unclosed block comment continues on the next line like the unclosed parentheses
+				depth++
+			}
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		}
+	}
+	return depth, inString
+}
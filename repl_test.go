@@ -3,6 +3,7 @@ package goscheme
 import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -22,3 +23,30 @@ func TestNeededIndents(t *testing.T) {
 		assert.Equal(t, c.expected, ret)
 	}
 }
+
+func TestREPL(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(+ 1 2)\n", ">>> #=>3\n>>> \n"},
+		{"(define (f x)\n  (* x 2))\n(f 21)\n", ">>> ... >>> #=>42\n>>> \n"},
+		{"\"a (\nb\"\n", ">>> ... #=>\"a (\nb\"\n>>> \n"},
+		{"(display \"hi\") 'sym #\\(\n", ">>> hi#=>sym\n#=>#\\(\n>>> \n"},
+		{"(car 1)\n(+ 1 1)", ">>> err:=>argument is not a pair\n>>> \n#=>2\n"},
+		{"(+ 1\n", ">>> ... \nerr:=>syntax error: unexpected end of input\n"},
+		{"1)\n2\n", ">>> err:=>syntax error: missing (\n>>> #=>2\n>>> \n"},
+		{"(list 1 ; comment )\n 2)\n", ">>> ... #=>(1 2)\n>>> \n"},
+		{"(list 1 #;(a\n b) 2)\n", ">>> ... #=>(1 2)\n>>> \n"},
+		{"#| (\n|# 3\n", ">>> ... #=>3\n>>> \n"},
+		// the unspecified value is not printed
+		{"(define x 1)\n(set! x 2)\n(if #f #f)\n(values)\n'()\n(list (if #f #f))\n",
+			">>> >>> >>> >>> >>> #=>()\n>>> #=>(#<void>)\n>>> \n"},
+	}
+	for _, c := range testCases {
+		var out bytes.Buffer
+		err := NewREPL(strings.NewReader(c.input), &out).Run()
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, out.String(), c.input)
+	}
+}
@@ -0,0 +1,177 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+)
+
+// evalConsStream constructs the stream whose car is the value of the first expression and the cdr is the promise of
+// the second expression. A stream is either the empty list or a pair whose cdr is the promise of the rest stream.
+// (cons-stream a b)
+func evalConsStream(args []Expression, env *Env) (Expression, error) {
+	if len(args) != 2 {
+		return UndefObj, errors.New("cons-stream: syntax error (requires 2 arguments)")
+	}
+	car, err := Eval(args[0], env)
+	if err != nil {
+		return UndefObj, err
+	}
+	return &Pair{car, NewThunk(args[1], env)}, nil
+}
+
+// lazyStream returns the promise of the stream computed by fn, which is called with the environment forcing the
+// promise.
+func lazyStream(fn func(env *Env) (Expression, error)) *Thunk {
+	return &Thunk{compute: fn}
+}
+
+// expressionToStreamPair returns the first pair of the non-empty stream.
+func expressionToStreamPair(name string, exp Expression) (*Pair, error) {
+	p, ok := exp.(*Pair)
+	if !ok || p.IsNull() {
+		return nil, fmt.Errorf("%s: %v is not a non-empty stream", name, valueToString(exp))
+	}
+	return p, nil
+}
+
+// streamRest forces the promise in the cdr of the stream pair.
+func streamRest(env *Env, p *Pair) (Expression, error) {
+	return actualValue(env, p.Cdr)
+}
+
+func streamCarFunc(args ...Expression) (Expression, error) {
+	p, err := expressionToStreamPair("stream-car", args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return p.Car, nil
+}
+
+func streamCdrFunc(env *Env, args ...Expression) (Expression, error) {
+	p, err := expressionToStreamPair("stream-cdr", args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return streamRest(env, p)
+}
+
+// streamTail returns the stream after dropping k elements of the stream.
+func streamTail(env *Env, name string, s, index Expression) (Expression, error) {
+	k, ok := index.(Integer)
+	if !ok || k < 0 {
+		return UndefObj, fmt.Errorf("%s: %v is not a valid index", name, valueToString(index))
+	}
+	for ; k > 0; k-- {
+		p, err := expressionToStreamPair(name, s)
+		if err != nil {
+			return UndefObj, err
+		}
+		if s, err = streamRest(env, p); err != nil {
+			return UndefObj, err
+		}
+	}
+	return s, nil
+}
+
+func streamRefFunc(env *Env, args ...Expression) (Expression, error) {
+	s, err := streamTail(env, "stream-ref", args[0], args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	p, err := expressionToStreamPair("stream-ref", s)
+	if err != nil {
+		return UndefObj, err
+	}
+	return p.Car, nil
+}
+
+// streamTakeFunc returns the list of the first k elements of the stream, the stream shorter than k is an error.
+func streamTakeFunc(env *Env, args ...Expression) (Expression, error) {
+	k, ok := args[1].(Integer)
+	if !ok || k < 0 {
+		return UndefObj, fmt.Errorf("stream-take: %v is not a valid count", valueToString(args[1]))
+	}
+	elements := make([]Expression, 0, k)
+	s := args[0]
+	for i := Integer(0); i < k; i++ {
+		p, err := expressionToStreamPair("stream-take", s)
+		if err != nil {
+			return UndefObj, err
+		}
+		elements = append(elements, p.Car)
+		if i < k-1 {
+			if s, err = streamRest(env, p); err != nil {
+				return UndefObj, err
+			}
+		}
+	}
+	return listImpl(elements...)
+}
+
+// streamMapFunc returns the stream of applying the procedure to the corresponding elements of the streams, the
+// result ends when the shortest stream ends. The elements are computed when the stream is walked.
+func streamMapFunc(env *Env, args ...Expression) (Expression, error) {
+	return streamMap(env, args[0], args[1:])
+}
+
+func streamMap(env *Env, fn Expression, streams []Expression) (Expression, error) {
+	pairs := make([]*Pair, len(streams))
+	elements := make([]Expression, len(streams))
+	for i, s := range streams {
+		if IsNullExp(s) {
+			return NilObj, nil
+		}
+		p, err := expressionToStreamPair("stream-map", s)
+		if err != nil {
+			return UndefObj, err
+		}
+		pairs[i], elements[i] = p, p.Car
+	}
+	car, err := callProcedure(env, fn, elements...)
+	if err != nil {
+		return UndefObj, err
+	}
+	return &Pair{car, lazyStream(func(env *Env) (Expression, error) {
+		rests := make([]Expression, len(pairs))
+		for i, p := range pairs {
+			rest, err := streamRest(env, p)
+			if err != nil {
+				return UndefObj, err
+			}
+			rests[i] = rest
+		}
+		return streamMap(env, fn, rests)
+	})}, nil
+}
+
+// streamFilterFunc returns the stream of the elements satisfying the predicate, the elements are checked when the
+// stream is walked.
+func streamFilterFunc(env *Env, args ...Expression) (Expression, error) {
+	return streamFilter(env, args[0], args[1])
+}
+
+func streamFilter(env *Env, predicate, s Expression) (Expression, error) {
+	for !IsNullExp(s) {
+		p, err := expressionToStreamPair("stream-filter", s)
+		if err != nil {
+			return UndefObj, err
+		}
+		ret, err := callProcedure(env, predicate, p.Car)
+		if err != nil {
+			return UndefObj, err
+		}
+		if IsTrue(ret) {
+			return &Pair{p.Car, lazyStream(func(env *Env) (Expression, error) {
+				rest, err := streamRest(env, p)
+				if err != nil {
+					return UndefObj, err
+				}
+				return streamFilter(env, predicate, rest)
+			})}, nil
+		}
+		if s, err = streamRest(env, p); err != nil {
+			return UndefObj, err
+		}
+	}
+	return NilObj, nil
+}
@@ -0,0 +1,48 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	integers := `(define (integers-from n) (cons-stream n (integers-from (+ n 1))))
+		(define nat (integers-from 0)) `
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(stream-car (cons-stream 1 (/ 1 0)))`, "1"},
+		{integers + `(stream-car (stream-cdr nat))`, "1"},
+		{integers + `(stream-ref nat 100)`, "100"},
+		{integers + `(stream-take nat 5)`, "(0 1 2 3 4)"},
+		{integers + `(stream-take (stream-map * nat nat) 5)`, "(0 1 4 9 16)"},
+		{integers + `(stream-take (stream-filter even? nat) 5)`, "(0 2 4 6 8)"},
+		{integers + `(stream-ref (stream-filter (lambda (x) (= 0 (modulo x 7))) nat) 1000)`, "7000"},
+		{`(stream-take (stream-map + (cons-stream 1 (cons-stream 2 '())) (cons-stream 10 '())) 1)`, "(11)"},
+		{`(stream-cdr (stream-map + (cons-stream 1 (cons-stream 2 '())) (cons-stream 10 '())))`, "()"},
+		{`(stream-filter odd? (cons-stream 2 (cons-stream 4 '())))`, "()"},
+		// the tail is evaluated only once
+		{`(define count 0) (define s (cons-stream 1 (begin (set! count (+ count 1)) '())))
+		  (stream-cdr s) (stream-cdr s) count`, "1"},
+		{integers + `(stream-ref nat 100000)`, "100000"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(stream-car '())`, "stream-car: () is not a non-empty stream"},
+		{`(stream-take (cons-stream 1 '()) 2)`, "stream-take: () is not a non-empty stream"},
+		{`(stream-ref (cons-stream 1 '()) -1)`, "stream-ref: -1 is not a valid index"},
+	}
+	for _, c := range errorCases {
+		_, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
@@ -0,0 +1,249 @@
+package goscheme
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func expressionToString(exp Expression) (String, error) {
+	s, ok := exp.(String)
+	if !ok {
+		return "", fmt.Errorf("%v is not a string", valueToString(exp))
+	}
+	return s, nil
+}
+
+// stringIndex checks the index argument of the procedure named name is in range [min, max].
+// The strings are indexed by characters rather than bytes.
+func stringIndex(name string, exp Expression, min, max int) (int, error) {
+	k, ok := exp.(Integer)
+	if !ok {
+		return 0, fmt.Errorf("%s: %v is not an exact integer", name, valueToString(exp))
+	}
+	if max < min {
+		return 0, fmt.Errorf("%s: index %v out of range of empty string", name, k)
+	}
+	if int(k) < min || int(k) > max {
+		return 0, fmt.Errorf("%s: index %v out of range [%d, %d]", name, k, min, max)
+	}
+	return int(k), nil
+}
+
+func stringAppendFunc(args ...Expression) (Expression, error) {
+	var buf strings.Builder
+	for _, arg := range args {
+		s, err := expressionToString(arg)
+		if err != nil {
+			return UndefObj, err
+		}
+		buf.WriteString(string(s))
+	}
+	return String(buf.String()), nil
+}
+
+// substringFunc returns the characters of the string from start to the optional end, which defaults to the
+// length of string.
+func substringFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	runes := []rune(string(s))
+	start, err := stringIndex("substring", args[1], 0, len(runes))
+	if err != nil {
+		return UndefObj, err
+	}
+	end := len(runes)
+	if len(args) > 2 {
+		if end, err = stringIndex("substring", args[2], start, len(runes)); err != nil {
+			return UndefObj, err
+		}
+	}
+	return String(runes[start:end]), nil
+}
+
+func stringLengthFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Integer(utf8.RuneCountInString(string(s))), nil
+}
+
+func stringRefFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	runes := []rune(string(s))
+	k, err := stringIndex("string-ref", args[1], 0, len(runes)-1)
+	if err != nil {
+		return UndefObj, err
+	}
+	return Char(runes[k]), nil
+}
+
+func stringToListFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	var chars []Expression
+	for _, r := range string(s) {
+		chars = append(chars, Char(r))
+	}
+	return listImpl(chars...)
+}
+
+func listToStringFunc(args ...Expression) (Expression, error) {
+	if !isList(args[0]) {
+		return UndefObj, fmt.Errorf("list->string: %v is not a list", valueToString(args[0]))
+	}
+	var buf strings.Builder
+	for _, exp := range extractList(args[0]) {
+		c, err := expressionToChar(exp)
+		if err != nil {
+			return UndefObj, fmt.Errorf("list->string: %v", err)
+		}
+		buf.WriteRune(rune(c))
+	}
+	return String(buf.String()), nil
+}
+
+// stringMapper returns the builtin converting the string with fn.
+func stringMapper(fn func(string) string) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		s, err := expressionToString(args[0])
+		if err != nil {
+			return UndefObj, err
+		}
+		return String(fn(string(s))), nil
+	}
+}
+
+// stringSplitFunc splits the string by the separator string or character into the list of substrings.
+// The empty fields between the consecutive separators or at the ends are kept, so joining the substrings with the
+// separator gives the original string.
+func stringSplitFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	var sep string
+	switch v := args[1].(type) {
+	case String:
+		sep = string(v)
+	case Char:
+		sep = string(v)
+	default:
+		return UndefObj, fmt.Errorf("string-split: %v is not a string or char", valueToString(args[1]))
+	}
+	if sep == "" {
+		return UndefObj, errors.New("string-split: empty separator")
+	}
+	var fields []Expression
+	for _, field := range strings.Split(string(s), sep) {
+		fields = append(fields, String(field))
+	}
+	return listImpl(fields...)
+}
+
+// compareStrings checks every adjacent pair of strings with predicate on the result of strings.Compare, which
+// orders the strings by code points. The strings are compared in lower case if foldCase is true.
+func compareStrings(args []Expression, foldCase bool, predicate func(int) bool) (Expression, error) {
+	for i := 0; i < len(args)-1; i++ {
+		a, err := expressionToString(args[i])
+		if err != nil {
+			return UndefObj, err
+		}
+		b, err := expressionToString(args[i+1])
+		if err != nil {
+			return UndefObj, err
+		}
+		x, y := string(a), string(b)
+		if foldCase {
+			x, y = strings.ToLower(x), strings.ToLower(y)
+		}
+		if !predicate(strings.Compare(x, y)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// stringComparator returns the builtin comparing the strings with predicate, see compareStrings.
+func stringComparator(foldCase bool, predicate func(int) bool) func(args ...Expression) (Expression, error) {
+	return func(args ...Expression) (Expression, error) {
+		return compareStrings(args, foldCase, predicate)
+	}
+}
+
+// stringContainsFunc returns the character index of the first occurrence of the pattern in the string, or #f if
+// the pattern is not found.
+func stringContainsFunc(args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	pattern, err := expressionToString(args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	i := strings.Index(string(s), string(pattern))
+	if i < 0 {
+		return false, nil
+	}
+	return Integer(utf8.RuneCountInString(string(s[:i]))), nil
+}
+
+// stringIndexFunc returns the index of the first character equal to the char or satisfying the predicate, or #f if
+// there is none.
+func stringIndexFunc(env *Env, args ...Expression) (Expression, error) {
+	s, err := expressionToString(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	i := 0
+	for _, r := range string(s) {
+		var matched bool
+		if c, ok := args[1].(Char); ok {
+			matched = rune(c) == r
+		} else {
+			ret, err := callProcedure(env, args[1], Char(r))
+			if err != nil {
+				return UndefObj, err
+			}
+			matched = IsTrue(ret)
+		}
+		if matched {
+			return Integer(i), nil
+		}
+		i++
+	}
+	return false, nil
+}
+
+// stringJoinFunc joins the list of strings with the separator, which defaults to a space.
+func stringJoinFunc(args ...Expression) (Expression, error) {
+	if !isList(args[0]) {
+		return UndefObj, fmt.Errorf("string-join: %v is not a list", valueToString(args[0]))
+	}
+	sep := String(" ")
+	if len(args) > 1 {
+		var err error
+		if sep, err = expressionToString(args[1]); err != nil {
+			return UndefObj, err
+		}
+	}
+	var fields []string
+	for _, exp := range extractList(args[0]) {
+		s, err := expressionToString(exp)
+		if err != nil {
+			return UndefObj, fmt.Errorf("string-join: %v", err)
+		}
+		fields = append(fields, string(s))
+	}
+	return String(strings.Join(fields, string(sep))), nil
+}
@@ -0,0 +1,194 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStringAppendAndSubstring(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(string-append)`, String("")},
+		{`(string-append "foo")`, String("foo")},
+		{`(string-append "foo" "" "bar" "baz")`, String("foobarbaz")},
+		{`(substring "hello" 1 3)`, String("el")},
+		{`(substring "hello" 1)`, String("ello")},
+		{`(substring "hello" 5)`, String("")},
+		{`(substring "hello" 2 2)`, String("")},
+		{`(substring "héllo" 1 3)`, String("él")},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(substring "hello" 6)`, "substring: index 6 out of range [0, 5]"},
+		{`(substring "hello" 3 2)`, "substring: index 2 out of range [3, 5]"},
+		{`(substring "hello" 1 9)`, "substring: index 9 out of range [1, 5]"},
+		{`(string-append "a" 1)`, "1 is not a string"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestStringChars(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(string-length "hello")`, Integer(5)},
+		{`(string-length "héllo")`, Integer(5)},
+		{`(string-length "")`, Integer(0)},
+		{`(string-ref "héllo" 1)`, Char('é')},
+		{`(string-ref "héllo" 4)`, Char('o')},
+		{`(string->list "hé")`, &Pair{Char('h'), &Pair{Char('é'), NilObj}}},
+		{`(string->list "")`, NilObj},
+		{`(list->string (list #\h #\é))`, String("hé")},
+		{`(list->string '())`, String("")},
+		{`(list->string (string->list "héllo"))`, String("héllo")},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, ret, c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-ref "héllo" 5)`, "string-ref: index 5 out of range [0, 4]"},
+		{`(string-ref "" 0)`, "string-ref: index 0 out of range of empty string"},
+		{`(list->string (list #\a 1))`, "list->string: 1 is not a character"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestStringCaseAndSplit(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-upcase "Hello, World")`, `"HELLO, WORLD"`},
+		{`(string-downcase "Hello, World")`, `"hello, world"`},
+		{`(string-upcase "héllo")`, `"HÉLLO"`},
+		{`(string-split "a,b,c" ",")`, `("a" "b" "c")`},
+		{`(string-split "a,b,c" #\,)`, `("a" "b" "c")`},
+		{`(string-split "a,,b," ",")`, `("a" "" "b" "")`},
+		{`(string-split "a::b" "::")`, `("a" "b")`},
+		{`(string-split "abc" ",")`, `("abc")`},
+		{`(string-split "" ",")`, `("")`},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-split "abc" "")`, "string-split: empty separator"},
+		{`(string-split "abc" 1)`, "string-split: 1 is not a string or char"},
+		{`(string-upcase 'a)`, "a is not a string"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Expression
+	}{
+		{`(string=? "abc" "abc")`, true},
+		{`(string=? "abc" "abc" "abd")`, false},
+		{`(string<? "abc" "abd" "b")`, true},
+		{`(string<? "abc" "ab")`, false},
+		{`(string>? "b" "abc")`, true},
+		{`(string<=? "a" "a" "b")`, true},
+		{`(string>=? "b" "c")`, false},
+		{`(string<? "Z" "a")`, true},
+		{`(string<? "z" "é")`, true},
+		{`(string-ci=? "Hello" "hELLO")`, true},
+		{`(string-ci<? "a" "B")`, true},
+		{`(string-ci>? "a" "B")`, false},
+		{`(string-ci<=? "A" "a")`, true},
+		{`(string-ci>=? "b" "A")`, true},
+		{`(sort '("pear" "apple" "fig") string<?)`, "(\"apple\" \"fig\" \"pear\")"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		if s, ok := c.expected.(string); ok {
+			assert.Equal(t, s, valueToString(ret), c.input)
+		} else {
+			assert.Equal(t, c.expected, ret, c.input)
+		}
+	}
+
+	env := setupBuiltinEnv()
+	_, err := EvalAll(strToToken(`(string<? "a" 'b)`), env)
+	assert.EqualError(t, err, "b is not a string")
+}
+
+func TestStringSearchAndJoin(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-contains "hello world" "o w")`, "4"},
+		{`(string-contains "hello" "")`, "0"},
+		{`(string-contains "héllo" "llo")`, "2"},
+		{`(string-contains "hello" "xyz")`, "#f"},
+		{`(string-index "hello" #\l)`, "2"},
+		{`(string-index "héllo" (lambda (c) (char=? c #\l)))`, "2"},
+		{`(string-index "hello" #\z)`, "#f"},
+		{`(string-join '("a" "b") "-")`, `"a-b"`},
+		{`(string-join '("a" "b" "c"))`, `"a b c"`},
+		{`(string-join '() ",")`, `""`},
+		{`(string-join (string-split "a,b,,c" ",") ",")`, `"a,b,,c"`},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-join '("a" 1) ",")`, "string-join: 1 is not a string"},
+		{`(string-join "a" ",")`, `string-join: "a" is not a list`},
+		{`(string-contains "a" 1)`, "1 is not a string"},
+	}
+	for _, c := range errorCases {
+		env := setupBuiltinEnv()
+		_, err := EvalAll(strToToken(c.input), env)
+		assert.EqualError(t, err, c.expected, c.input)
+	}
+}
@@ -2,10 +2,14 @@ package goscheme
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Expression represent the parsed tokens of scheme syntax tree or the low level builtin types.
@@ -19,9 +23,12 @@ type String string
 
 // String return the string to display wrapping the low level string with quotes.
 func (s String) String() string {
-	return "\"" + string(s) + "\""
+	return "\"" + stringEscaper.Replace(string(s)) + "\""
 }
 
+// stringEscaper escapes the backslashes and double quotes in the string literal.
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 // SyntaxMap contains all defined scheme syntax.
 var SyntaxMap = make(map[string]*Syntax)
 
@@ -49,23 +56,42 @@ func NewSyntax(name string, fn SyntaxFunc) *Syntax {
 	return &Syntax{fn, name}
 }
 
+// syntaxOnce guards initSyntax so the environments can be set up concurrently.
+var syntaxOnce sync.Once
+
 func initSyntax() {
 	SyntaxMap["define"] = NewSyntax("define", evalDefine)
+	SyntaxMap["define-syntax"] = NewSyntax("define-syntax", evalDefineSyntax)
+	SyntaxMap["define-macro"] = NewSyntax("define-macro", evalDefineMacro)
 	SyntaxMap["eval"] = NewSyntax("eval", evalEval)
 	SyntaxMap["apply"] = NewSyntax("apply", evalApply)
 	SyntaxMap["if"] = NewSyntax("if", evalIf)
 	SyntaxMap["cond"] = NewSyntax("cond", evalCond)
+	SyntaxMap["case"] = NewSyntax("case", evalCase)
+	SyntaxMap["when"] = NewSyntax("when", evalWhen)
+	SyntaxMap["do"] = NewSyntax("do", evalDo)
+	SyntaxMap["unless"] = NewSyntax("unless", evalUnless)
 	SyntaxMap["begin"] = NewSyntax("begin", evalBegin)
 	SyntaxMap["lambda"] = NewSyntax("lambda", evalLambda)
 	SyntaxMap["load"] = NewSyntax("load", evalLoad)
 	SyntaxMap["delay"] = NewSyntax("delay", evalDelay)
+	SyntaxMap["delay-force"] = NewSyntax("delay-force", evalDelay)
+	SyntaxMap["lazy"] = NewSyntax("lazy", evalDelay)
+	SyntaxMap["cons-stream"] = NewSyntax("cons-stream", evalConsStream)
 	SyntaxMap["and"] = NewSyntax("and", evalAnd)
-	SyntaxMap["or"] = NewSyntax("and", evalOr)
+	SyntaxMap["or"] = NewSyntax("or", evalOr)
 	SyntaxMap["let"] = NewSyntax("let", evalLet)
-	SyntaxMap["let*"] = NewSyntax("let*", evalL2RLet)
-	SyntaxMap["letrec"] = NewSyntax("letrec", evalLetRec)
+	SyntaxMap["let*"] = NewSyntax("let*", evalLetStar)
+	SyntaxMap["letrec"] = NewSyntax("letrec", evalLetrec)
+	SyntaxMap["letrec*"] = NewSyntax("letrec*", evalLetrec)
 	SyntaxMap["quote"] = NewSyntax("quote", evalQuote)
+	SyntaxMap["quasiquote"] = NewSyntax("quasiquote", evalQuasiquote)
 	SyntaxMap["set!"] = NewSyntax("set!", evalSet)
+	SyntaxMap["assert"] = NewSyntax("assert", evalAssert)
+	SyntaxMap["guard"] = NewSyntax("guard", evalGuard)
+	SyntaxMap["parameterize"] = NewSyntax("parameterize", evalParameterize)
+	SyntaxMap["let-values"] = NewSyntax("let-values", evalLetValues)
+	SyntaxMap["receive"] = NewSyntax("receive", evalReceive)
 }
 
 // Symbol represents the variable name in scheme.
@@ -76,19 +102,37 @@ type Quote string
 
 type commonFunction func(args ...Expression) (Expression, error)
 
+// envFunction is the function receiving the environment of the caller, the procedures it calls are evaluated with
+// the dynamic state of the caller.
+type envFunction func(env *Env, args ...Expression) (Expression, error)
+
 // Function represents the basic scheme function in pure go.
 type Function struct {
 	name     string
 	function commonFunction
-	minArgs  int
-	maxArgs  int
+	// replaces function if not nil
+	envFunction envFunction
+	minArgs     int
+	maxArgs     int
+	// id identifies the function created by NewFunction, the copies of the function are identical in eqv?
+	id uint64
 }
 
+var functionCounter uint64
+
 // Call eval the function with args and returns the result.
 func (f Function) Call(args ...Expression) (Expression, error) {
+	return f.call(nil, args...)
+}
+
+// call evaluates the function called in env, the nil env means no evaluation in progress.
+func (f Function) call(env *Env, args ...Expression) (Expression, error) {
 	if err := f.validateArgCount(args...); err != nil {
 		return UndefObj, err
 	}
+	if f.envFunction != nil {
+		return f.envFunction(env, args...)
+	}
 	return f.function(args...)
 }
 
@@ -122,6 +166,18 @@ func NewFunction(funcName string, f commonFunction, minArgs int, maxArgs int) Fu
 		function: f,
 		minArgs:  minArgs,
 		maxArgs:  maxArgs,
+		id:       atomic.AddUint64(&functionCounter, 1),
+	}
+}
+
+// newEnvFunction returns the Function calling f with the environment of the caller, see NewFunction.
+func newEnvFunction(funcName string, f envFunction, minArgs int, maxArgs int) Function {
+	return Function{
+		name:        funcName,
+		envFunction: f,
+		minArgs:     minArgs,
+		maxArgs:     maxArgs,
+		id:          atomic.AddUint64(&functionCounter, 1),
 	}
 }
 
@@ -134,6 +190,10 @@ type Thunk struct {
 	ret Expression
 	// context to execute Exp
 	Env *Env
+	// the promise which took over the evaluation of this one, see Value
+	forward *Thunk
+	// computes the value instead of evaluating Exp if not nil, used by the promises created by builtins
+	compute func(env *Env) (Expression, error)
 }
 
 // String returns the string represents the Thunk struct.
@@ -141,30 +201,63 @@ func (t Thunk) String() string {
 	if t.ret != nil {
 		return fmt.Sprintf("#[Thunk %s]", t.ret)
 	}
+	if t.compute != nil {
+		return "#[Thunk]"
+	}
 	return fmt.Sprintf("#[Thunk exp: %s]", t.Exp)
 }
 
-// Value returns the actual value of the thunk
+// Value returns the actual value of the thunk, the expression is evaluated only once and the value is cached.
+// If the expression evaluates to another promise, the thunk takes over the evaluation of that promise in the same
+// loop instead of forcing it recursively, so forcing a long chain of promises runs in constant space.
 func (t *Thunk) Value() (Expression, error) {
-	if t.ret != nil {
-		return t.ret, nil
-	}
-	value, err := Eval(t.Exp, t.Env)
-	if err != nil {
-		return UndefObj, err
-	}
-	switch t2 := value.(type) {
-	case *Thunk:
-		value, err = t2.Value()
-	default:
-	}
-	if err != nil {
-		return UndefObj, err
+	return t.force(nil)
+}
+
+// force returns the value of the thunk like Value, the expression is evaluated as part of the evaluation in env.
+func (t *Thunk) force(env *Env) (Expression, error) {
+	t = t.resolve()
+	for t.ret == nil {
+		var value Expression
+		var err error
+		if t.compute != nil {
+			value, err = t.compute(env)
+		} else {
+			value, err = Eval(t.Exp, t.Env.withDynamic(env.dynamicState()))
+		}
+		if err != nil {
+			return UndefObj, err
+		}
+		if t.ret != nil {
+			// the thunk was forced by its own expression, the value forced first is kept
+			break
+		}
+		next, ok := value.(*Thunk)
+		if !ok {
+			t.ret = value
+			break
+		}
+		next = next.resolve()
+		if next.ret != nil {
+			t.ret = next.ret
+			break
+		}
+		if next != t {
+			t.Exp, t.Env, t.compute = next.Exp, next.Env, next.compute
+			next.forward = t
+		}
 	}
-	t.ret = value
 	return t.ret, nil
 }
 
+// resolve returns the thunk evaluating the value of t.
+func (t *Thunk) resolve() *Thunk {
+	for t.forward != nil {
+		t = t.forward
+	}
+	return t
+}
+
 // IsThunk checks whether an expression is a thunk and return the result
 func IsThunk(exp Expression) bool {
 	switch exp.(type) {
@@ -183,14 +276,60 @@ func NewThunk(exp Expression, env *Env) *Thunk {
 // ActualValue returns the actual value of an expression.
 // If the expression is a Thunk, eval and return the result, otherwise return the expression itself.
 func ActualValue(exp Expression) (Expression, error) {
+	return actualValue(nil, exp)
+}
+
+// actualValue returns the actual value of the expression like ActualValue, the thunk is forced as part of the
+// evaluation in env.
+func actualValue(env *Env, exp Expression) (Expression, error) {
 	switch p := exp.(type) {
 	case *Thunk:
-		return p.Value()
+		return p.force(env)
 	default:
 		return exp, nil
 	}
 }
 
+// Continuation represents the escape-only continuation captured by call/cc.
+// Invoking the continuation unwinds the stack back to the call/cc which captured it, so it can only be used to escape
+// from the dynamic extent of call/cc, re-entering a continuation after call/cc returned is not supported.
+type Continuation struct {
+	// whether the call/cc which captured the continuation has returned
+	expired bool
+}
+
+// continuationInvoked is the panic value carrying the arguments passed to the continuation.
+type continuationInvoked struct {
+	k     *Continuation
+	value Expression
+}
+
+// Invoke passes the value back to the call/cc which captured the continuation.
+func (k *Continuation) Invoke(args ...Expression) (Expression, error) {
+	if k.expired {
+		return UndefObj, errors.New("continuation can only be used to escape from call/cc")
+	}
+	var value Expression = UndefObj
+	if len(args) > 0 {
+		value = args[0]
+	}
+	panic(&continuationInvoked{k, value})
+}
+
+// Values holds the multiple values returned by values.
+// Multiple values in a context expecting a single value are not unpacked, they are passed around as a Values which
+// displays the values separated by space.
+type Values []Expression
+
+// String returns the values separated by space.
+func (v Values) String() string {
+	var s []string
+	for _, item := range v {
+		s = append(s, valueToString(item))
+	}
+	return strings.Join(s, " ")
+}
+
 // NilType represents Nil in scheme
 type NilType struct{}
 
@@ -202,12 +341,13 @@ func (n NilType) String() string {
 // NilObj is the common object of NilType
 var NilObj = NilType{}
 
-// Undef represents undefined expression value.
+// Undef represents undefined expression value, which is the unspecified value returned by define, set! and the
+// procedures called for side effects.
 type Undef struct{}
 
-// String just implements the Stringer interface.
+// String returns the canonical representation of the unspecified value, which is written by display and write.
 func (u Undef) String() string {
-	return "<UNDEF>"
+	return "#<void>"
 }
 
 func extractList(expression Expression) (ret []Expression) {
@@ -227,31 +367,31 @@ func extractList(expression Expression) (ret []Expression) {
 // UndefObj is the common Undef object.
 var UndefObj = Undef{}
 
+// unassigned marks the variable which is bound but not initialized yet, e.g. the variables of letrec.
+type unassigned struct{}
+
+var unassignedObj = unassigned{}
+
 // IsNumber check whether the expression represents Number.
 func IsNumber(exp Expression) bool {
 	switch v := exp.(type) {
 	case string:
-		_, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return false
-		}
-		return true
-	case Number:
+		_, ok := numberLiteral(v)
+		return ok
+	case Number, Integer, *big.Int, *big.Rat:
 		return true
 	default:
 		return false
 	}
 }
 
+var stringPattern = regexp.MustCompile("\"(.|[\\r\\n])*\"")
+
 // IsString check whether the expression represents String in scheme.
 func IsString(exp Expression) bool {
 	switch v := exp.(type) {
 	case string:
-		ok, err := regexp.MatchString("\"(.|[\\r\\n])*\"", v)
-		if ok && err == nil {
-			return true
-		}
-		return false
+		return stringPattern.MatchString(v)
 	case String:
 		return true
 	default:
@@ -262,17 +402,15 @@ func IsString(exp Expression) bool {
 // IsSyntaxExpression check whether the expression is a scheme syntax expression.
 func IsSyntaxExpression(exp Expression) bool {
 	ops, ok := exp.([]Expression)
-	if !ok {
+	if !ok || len(ops) == 0 {
 		return false
 	}
-	operator := ops[0]
-
-	for key := range SyntaxMap {
-		if key == operator {
-			return true
-		}
+	operator, ok := ops[0].(string)
+	if !ok {
+		return false
 	}
-	return false
+	_, ok = SyntaxMap[operator]
+	return ok
 }
 
 // IsSymbol checks whether the expression is Symbol.
@@ -284,7 +422,7 @@ func IsSymbol(expression Expression) bool {
 	if _, ok := expression.(string); !ok {
 		return false
 	}
-	if IsNumber(expression) || IsString(expression) || IsBoolean(expression) {
+	if IsNumber(expression) || IsString(expression) || IsBoolean(expression) || IsChar(expression) {
 		return false
 	}
 	return true
@@ -296,12 +434,12 @@ func IsBoolean(exp Expression) bool {
 	if ok {
 		return true
 	}
-	return exp == "#t" || exp == "#f"
+	return exp == "#t" || exp == "#f" || exp == "#true" || exp == "#false"
 }
 
-// IsTrue check whether the condition is true. Return false when Exp is #f or false, otherwise return true
+// IsTrue check whether the condition is true. Return false when Exp is #f, #false or false, otherwise return true
 func IsTrue(exp Expression) bool {
-	if exp == "#f" || exp == false {
+	if exp == "#f" || exp == "#false" || exp == false {
 		return false
 	}
 	return true
@@ -340,6 +478,7 @@ func IsPair(obj Expression) bool {
 // LambdaProcess wraps the body and env of a lambda expression
 type LambdaProcess struct {
 	params []Symbol
+	rest   Symbol       // parameter collects the remaining arguments, empty if no rest parameter
 	body   []Expression // expressions of the lambda process
 	env    *Env
 }
@@ -350,9 +489,15 @@ func (lambda *LambdaProcess) String() string {
 	buf.WriteString("(lambda (")
 	for i, k := range lambda.params {
 		buf.WriteString(string(k))
-		if i != len(k)-1 {
+		if i != len(lambda.params)-1 {
+			buf.WriteString(" ")
+		}
+	}
+	if lambda.rest != "" {
+		if len(lambda.params) > 0 {
 			buf.WriteString(" ")
 		}
+		buf.WriteString(". " + string(lambda.rest))
 	}
 	buf.WriteString(") ")
 	buf.WriteString(concatLambdaBodyToString(lambda.body))
@@ -390,7 +535,32 @@ func concatLambdaBodyToString(expressions []Expression) string {
 	return buf.String()
 }
 
-// Body returns the expressions of body.
+// bindArguments creates the environment to evaluate the body, in which the parameters are bound to the arguments.
+// The body is evaluated as part of the evaluation of the caller in env.
+func (lambda *LambdaProcess) bindArguments(args []Expression, env *Env) (*Env, error) {
+	if lambda.rest == "" && len(args) != len(lambda.params) {
+		return nil, errors.New(fmt.Sprintf("%v\n", lambda.String()) + "require " + strconv.Itoa(len(lambda.params)) + " but " + strconv.Itoa(len(args)) + " provide")
+	}
+	if len(args) < len(lambda.params) {
+		return nil, errors.New(fmt.Sprintf("%v\n", lambda.String()) + "require at least " + strconv.Itoa(len(lambda.params)) + " but " + strconv.Itoa(len(args)) + " provide")
+	}
+	newEnv := extendEnv(lambda.env)
+	newEnv.dynamic = env.dynamicState()
+	for i, param := range lambda.params {
+		newEnv.Set(param, args[i])
+	}
+	if lambda.rest != "" {
+		rest, err := listImpl(args[len(lambda.params):]...)
+		if err != nil {
+			return nil, err
+		}
+		newEnv.Set(lambda.rest, rest)
+	}
+	return newEnv, nil
+}
+
+// Body returns the expression of body. The body with multiple expressions is wrapped in an implicit begin, so
+// the expressions are evaluated in order and only the last one is in tail position.
 func (lambda *LambdaProcess) Body() Expression {
 	if len(lambda.body) == 1 {
 		return lambda.body[0]
@@ -408,13 +578,11 @@ func (p *Pair) IsNull() bool {
 	return p.Car == nil && p.Cdr == nil
 }
 
-// IsList check whether the *Pair is a well formed list.
+// IsList check whether the *Pair is a well formed list, the circular list is not a well formed list.
 func (p *Pair) IsList() bool {
-	currentPair := p
-	for {
-		if currentPair.IsNull() {
-			return true
-		}
+	// the slow pointer moves one pair every two pairs and meets the current pair if the list is circular
+	slow, currentPair := p, p
+	for n := 1; !currentPair.IsNull(); n++ {
 		switch cdr := currentPair.Cdr.(type) {
 		case *Pair:
 			currentPair = cdr
@@ -423,7 +591,14 @@ func (p *Pair) IsList() bool {
 		default:
 			return false
 		}
+		if n%2 == 0 {
+			slow = slow.Cdr.(*Pair)
+			if slow == currentPair {
+				return false
+			}
+		}
 	}
+	return true
 }
 
 // String returns the string representing the *Pair.
@@ -437,7 +612,7 @@ func (p *Pair) String() string {
 		if IsPair(currentPair.Car) {
 			strSlices = append(strSlices, currentPair.Car.(*Pair).String())
 		} else {
-			strSlices = append(strSlices, fmt.Sprintf("%v", currentPair.Car))
+			strSlices = append(strSlices, valueToString(currentPair.Car))
 		}
 
 		if IsPair(currentPair.Cdr) {
@@ -447,7 +622,7 @@ func (p *Pair) String() string {
 				break
 			}
 			strSlices = append(strSlices, ".")
-			strSlices = append(strSlices, fmt.Sprintf("%v", currentPair.Cdr))
+			strSlices = append(strSlices, valueToString(currentPair.Cdr))
 			break
 		}
 	}
@@ -455,14 +630,17 @@ func (p *Pair) String() string {
 	return "(" + strings.Join(strSlices, " ") + ")"
 }
 
-// check the result should print in console
+// shouldPrint checks whether the result should be printed in the REPL. The unspecified value and no values are not
+// printed, while the empty list is printed as ().
 func shouldPrint(exp Expression) bool {
 	if exp == nil {
 		return false
 	}
-	switch exp.(type) {
+	switch v := exp.(type) {
 	case Undef:
 		return false
+	case Values:
+		return len(v) > 0
 	default:
 		return true
 	}
@@ -488,14 +666,26 @@ func valueToString(exp Expression) string {
 func IsPrimitiveExpression(exp Expression) bool {
 	if IsNullExp(exp) || IsUndefObj(exp) ||
 		IsQuote(exp) || IsNumber(exp) ||
-		IsBoolean(exp) || IsString(exp) ||
+		IsBoolean(exp) || IsString(exp) || IsChar(exp) ||
 		IsThunk(exp) || IsPair(exp) ||
-		isList(exp) || IsLambdaType(exp) {
+		isList(exp) || IsLambdaType(exp) ||
+		isSelfEvaluating(exp) {
 		return true
 	}
 	return false
 }
 
+// isSelfEvaluating checks whether the expression is a value rather than the token or list of syntax tree,
+// the values like Function, Values evaluate to themselves.
+func isSelfEvaluating(exp Expression) bool {
+	switch exp.(type) {
+	case string, []Expression:
+		return false
+	default:
+		return true
+	}
+}
+
 // IsQuote check whether the value is Quote.
 func IsQuote(exp Expression) bool {
 	_, ok := exp.(Quote)
@@ -527,3 +717,9 @@ func IsLambdaType(expression Expression) bool {
 	_, ok := expression.(*LambdaProcess)
 	return ok
 }
+
+// IsFunction checks whether this expression low level value is Function
+func IsFunction(expression Expression) bool {
+	_, ok := expression.(Function)
+	return ok
+}
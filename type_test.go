@@ -24,6 +24,47 @@ func TestPair_IsList(t *testing.T) {
 	for _, c := range testCases {
 		assert.Equal(t, c.Expected, c.Item.IsList())
 	}
+
+	// the circular lists of different lengths
+	for n := 1; n <= 5; n++ {
+		head := &Pair{0, NilObj}
+		last := head
+		for i := 1; i < n; i++ {
+			last.Cdr = &Pair{i, NilObj}
+			last = last.Cdr.(*Pair)
+		}
+		last.Cdr = head
+		assert.False(t, head.IsList(), n)
+		assert.False(t, (&Pair{"x", head}).IsList(), n)
+	}
+}
+
+func TestTypePredicates(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(list (pair? '(1)) (pair? '(1 . 2)) (pair? '()) (pair? #(1)) (pair? 1))", "(#t #t #f #f #f)"},
+		{"(list (null? '()) (null? '(1)) (null? #f))", "(#t #f #f)"},
+		{"(list (list? '()) (list? '(1 2)) (list? '(1 . 2)) (list? 1))", "(#t #t #f #f)"},
+		{"(define c (list 1 2 3)) (set-cdr! (cddr c) c) (list (list? c) (pair? c))", "(#f #t)"},
+		{"(define c (list 1)) (set-cdr! c c) (list? c)", "#f"},
+		{`(list (symbol? 'a) (symbol? "a") (symbol? #\a) (symbol? '()))`, "(#t #f #f #f)"},
+		{`(list (string? "a") (string? 'a) (string? #\a))`, "(#t #f #f)"},
+		{`(list (number? 1) (number? 1.5) (number? 1/2) (number? 100000000000000000000) (number? "1"))`,
+			"(#t #t #t #t #f)"},
+		{"(list (boolean? #t) (boolean? #false) (boolean? '()) (boolean? 0))", "(#t #t #f #f)"},
+		{`(list (char? #\a) (char? "a") (char? 97))`, "(#t #f #f)"},
+		{"(list (procedure? car) (procedure? (lambda (x) x)) (procedure? (make-parameter 1)) (procedure? 'car))",
+			"(#t #t #t #f)"},
+		{"(call/cc (lambda (k) (procedure? k)))", "#t"},
+		{"(list (procedure? if) (procedure? '(lambda (x) x)))", "(#f #f)"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
 }
 
 func TestPair_String(t *testing.T) {
@@ -52,6 +93,7 @@ func TestShouldPrint(t *testing.T) {
 	assert.Equal(t, false, shouldPrint(UndefObj))
 	assert.Equal(t, false, shouldPrint(nil))
 	assert.Equal(t, true, shouldPrint(NilObj))
+	assert.Equal(t, false, shouldPrint(Values{}))
 }
 
 func TestIsTrue(t *testing.T) {
@@ -62,4 +104,77 @@ func TestIsTrue(t *testing.T) {
 	assert.Equal(t, true, IsTrue(UndefObj))
 	assert.Equal(t, true, IsTrue(1))
 	assert.Equal(t, true, IsTrue(""))
+	assert.Equal(t, true, IsTrue("#true"))
+	assert.Equal(t, false, IsTrue("#false"))
+	assert.Equal(t, false, IsTrue(false))
+	assert.Equal(t, true, IsTrue(true))
+	assert.Equal(t, true, IsTrue(Integer(0)))
+	assert.Equal(t, true, IsTrue(Number(0)))
+	assert.Equal(t, true, IsTrue(String("")))
+	assert.Equal(t, true, IsTrue(Quote("nil")))
+}
+
+func TestTruthiness(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(not #f)", "#t"},
+		{"(not #t)", "#f"},
+		{"(not '())", "#f"},
+		{"(not 0)", "#f"},
+		{`(not "")`, "#f"},
+		{"(not 'nil)", "#f"},
+		{"(not (if #f #f))", "#f"},
+		{"(if '() 'yes 'no)", "yes"},
+		{"(if 0 'yes 'no)", "yes"},
+		{`(if "" 'yes 'no)`, "yes"},
+		{"(cond ('() 'yes) (else 'no))", "yes"},
+		{"(and 0 '() \"\")", `""`},
+		{"(or #f '())", "()"},
+		{"(when 0 'yes)", "yes"},
+		{"(unless '() 'yes)", "#<void>"},
+		{"(filter (lambda (x) x) '(0 #f () \"\"))", `(0 () "")`},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		assert.Nil(t, err, c.input)
+		assert.Equal(t, c.expected, writeString(ret), c.input)
+	}
+}
+
+func TestIsBoolean(t *testing.T) {
+	for _, exp := range []Expression{"#t", "#f", "#true", "#false", true, false} {
+		assert.True(t, IsBoolean(exp), exp)
+	}
+	for _, exp := range []Expression{"#tru", "#", "true", Quote("#t")} {
+		assert.False(t, IsBoolean(exp), exp)
+	}
+}
+
+func TestBooleans(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"#true", "#t"},
+		{"#false", "#f"},
+		{"(if #false 1 2)", "2"},
+		{"'(#t #false)", "(#t #f)"},
+		{"(eq? '#true #t)", "#t"},
+		{"(boolean=? #t #t)", "#t"},
+		{"(boolean=? #f #false)", "#t"},
+		{"(boolean=? #t #f)", "#f"},
+		{"(boolean=? #t #t #f)", "#f"},
+		{"(boolean=? #f #f #f)", "#t"},
+		{"(boolean=? #t 1)", "boolean=?: 1 is not a boolean"},
+	}
+	for _, c := range testCases {
+		ret, err := EvalAll(strToToken(c.input), setupBuiltinEnv())
+		if err != nil {
+			assert.Contains(t, err.Error(), c.expected, c.input)
+			continue
+		}
+		assert.Equal(t, c.expected, valueToString(ret), c.input)
+	}
 }
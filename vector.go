@@ -0,0 +1,147 @@
+package goscheme
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Vector represents the fixed-length vector in scheme. Should only use with pointer.
+type Vector struct {
+	Elements []Expression
+}
+
+// String returns the string representing the vector, e.g. #(1 2 3).
+func (v *Vector) String() string {
+	s := make([]string, len(v.Elements))
+	for i, e := range v.Elements {
+		s[i] = valueToString(e)
+	}
+	return "#(" + strings.Join(s, " ") + ")"
+}
+
+// vectorLiteral is the syntax tree of the vector literal #(...), the elements are quoted when evaluated.
+type vectorLiteral []Expression
+
+// datum quotes the elements of the literal to construct the vector.
+func (l vectorLiteral) datum() (*Vector, error) {
+	elements := make([]Expression, len(l))
+	for i, exp := range l {
+		q, err := evalQuote([]Expression{exp}, nil)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = q
+	}
+	return &Vector{elements}, nil
+}
+
+// IsVector checks whether the expression is a vector.
+func IsVector(exp Expression) bool {
+	_, ok := exp.(*Vector)
+	return ok
+}
+
+func expressionToVector(exp Expression) (*Vector, error) {
+	v, ok := exp.(*Vector)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a vector", valueToString(exp))
+	}
+	return v, nil
+}
+
+// vectorIndex checks the index argument of the procedure named name is valid for the vector.
+func vectorIndex(name string, v *Vector, exp Expression) (int, error) {
+	k, ok := exp.(Integer)
+	if !ok {
+		return 0, fmt.Errorf("%s: %v is not an exact integer", name, valueToString(exp))
+	}
+	if k < 0 || int(k) >= len(v.Elements) {
+		return 0, fmt.Errorf("%s: index %v out of range [0, %d)", name, k, len(v.Elements))
+	}
+	return int(k), nil
+}
+
+func makeVectorFunc(args ...Expression) (Expression, error) {
+	k, ok := args[0].(Integer)
+	if !ok || k < 0 {
+		return UndefObj, fmt.Errorf("make-vector: %v is not a valid length", valueToString(args[0]))
+	}
+	var fill Expression = Integer(0)
+	if len(args) > 1 {
+		fill = args[1]
+	}
+	elements := make([]Expression, k)
+	for i := range elements {
+		elements[i] = fill
+	}
+	return &Vector{elements}, nil
+}
+
+func vectorFunc(args ...Expression) (Expression, error) {
+	elements := make([]Expression, len(args))
+	copy(elements, args)
+	return &Vector{elements}, nil
+}
+
+func vectorRefFunc(args ...Expression) (Expression, error) {
+	v, err := expressionToVector(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	k, err := vectorIndex("vector-ref", v, args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	return v.Elements[k], nil
+}
+
+func vectorSetFunc(args ...Expression) (Expression, error) {
+	v, err := expressionToVector(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	k, err := vectorIndex("vector-set!", v, args[1])
+	if err != nil {
+		return UndefObj, err
+	}
+	v.Elements[k] = args[2]
+	return UndefObj, nil
+}
+
+func vectorLengthFunc(args ...Expression) (Expression, error) {
+	v, err := expressionToVector(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return Integer(len(v.Elements)), nil
+}
+
+func vectorToListFunc(args ...Expression) (Expression, error) {
+	v, err := expressionToVector(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	return listImpl(v.Elements...)
+}
+
+func listToVectorFunc(args ...Expression) (Expression, error) {
+	if !isList(args[0]) {
+		return UndefObj, fmt.Errorf("list->vector: %v is not a list", valueToString(args[0]))
+	}
+	return &Vector{extractList(args[0])}, nil
+}
+
+func vectorFillFunc(args ...Expression) (Expression, error) {
+	v, err := expressionToVector(args[0])
+	if err != nil {
+		return UndefObj, err
+	}
+	for i := range v.Elements {
+		v.Elements[i] = args[1]
+	}
+	return UndefObj, nil
+}
+
+func isVectorFunc(args ...Expression) (Expression, error) {
+	return IsVector(args[0]), nil
+}
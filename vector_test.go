@@ -0,0 +1,45 @@
+package goscheme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVector(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"#(1 2 3)", "#(1 2 3)"},
+		{"#()", "#()"},
+		{`'#(a "b" #\c (1 2))`, `#(a "b" #\c (1 2))`},
+		{"(vector 1 (+ 1 1) 'x)", "#(1 2 x)"},
+		{"(make-vector 3 'a)", "#(a a a)"},
+		{"(make-vector 2)", "#(0 0)"},
+		{"(vector-ref #(1 2 3) 1)", "2"},
+		{"(vector-length #(1 2 3))", "3"},
+		{"(vector->list #(1 2 3))", "(1 2 3)"},
+		{"(list->vector '(1 2 3))", "#(1 2 3)"},
+		{"(list->vector '())", "#()"},
+		{"(define v (vector 1 2 3)) (vector-set! v 0 'x) v", "#(x 2 3)"},
+		{"(define v (make-vector 2 0)) (vector-fill! v #t) v", "#(#t #t)"},
+		{"(vector? #(1))", "#t"},
+		{"(vector? '(1))", "#f"},
+	}
+	for _, c := range testCases {
+		env := setupBuiltinEnv()
+		ret, err := EvalAll(strToToken(c.input), env)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, valueToString(ret))
+	}
+}
+
+func TestVectorIndexOutOfRange(t *testing.T) {
+	env := setupBuiltinEnv()
+	for _, input := range []string{"(vector-ref #(1 2 3) 3)", "(vector-set! (vector 1) -1 0)", "(vector-ref #(1) 0.0)"} {
+		_, err := Eval(strToToken(input)[0], env)
+		assert.NotNil(t, err)
+	}
+	_, err := Eval(strToToken("(vector-ref #(1 2 3) 3)")[0], env)
+	assert.EqualError(t, err, "vector-ref: index 3 out of range [0, 3)")
+}